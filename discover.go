@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Feed advertised by an html page.
+type discoveredFeed struct {
+	URL   string
+	Title string
+	Type  string
+}
+
+// Link types that point at something parseFeed can handle.
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/rdf+xml":   true,
+}
+
+var linkTagPattern = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+var tagAttrPattern = regexp.MustCompile(`(?is)([a-z][a-z0-9_:-]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+// Returns the attributes of an html tag keyed by lower case name.
+func tagAttributes(tag string) map[string]string {
+	attrs := map[string]string{}
+	matches := tagAttrPattern.FindAllStringSubmatch(tag, -1)
+	for i := 0; i < len(matches); i++ {
+		value := matches[i][2] + matches[i][3] + matches[i][4]
+		attrs[strings.ToLower(matches[i][1])] = html.UnescapeString(strings.TrimSpace(value))
+	}
+	return attrs
+}
+
+// Finds the <link rel="alternate"> tags in an html page that point at feeds, resolving their hrefs against the
+// pages url.
+func findFeedLinks(page []byte, pageURL string) ([]discoveredFeed, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing page url: %w", err)
+	}
+
+	found := []discoveredFeed{}
+	seen := map[string]bool{}
+	tags := linkTagPattern.FindAll(page, -1)
+	for i := 0; i < len(tags); i++ {
+		attrs := tagAttributes(string(tags[i]))
+
+		is_alternate := false
+		rels := strings.Fields(strings.ToLower(attrs["rel"]))
+		for j := 0; j < len(rels); j++ {
+			if rels[j] == "alternate" {
+				is_alternate = true
+			}
+		}
+		link_type := strings.ToLower(strings.TrimSpace(strings.Split(attrs["type"], ";")[0]))
+		if !is_alternate || !feedLinkTypes[link_type] || attrs["href"] == "" {
+			continue
+		}
+
+		href, err := url.Parse(attrs["href"])
+		if err != nil {
+			continue
+		}
+		feed_url := base.ResolveReference(href).String()
+		if seen[feed_url] {
+			continue
+		}
+		seen[feed_url] = true
+
+		found = append(found, discoveredFeed{URL: feed_url, Title: attrs["title"], Type: link_type})
+	}
+	return found, nil
+}
+
+// Reports whether a response is an html page rather than a feed.
+func isHTMLPage(contentType string, data []byte) bool {
+	media_type := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if media_type == "text/html" || media_type == "application/xhtml+xml" {
+		return true
+	}
+	return strings.HasPrefix(http.DetectContentType(bytes.TrimSpace(data)), "text/html")
+}
+
+// Works out which feeds live at a url. If the url is a feed itself it's the only result, if it's an html page the
+// feeds the page advertises are returned. Anything else is an ErrNotAFeed error.
+func discoverFeeds(ctx context.Context, pageURL string, maxBytes int64) ([]discoveredFeed, error) {
+	result, err := fetchDocument(ctx, pageURL, fetchOptions{MaxBytes: maxBytes})
+	if err != nil {
+		return nil, err
+	}
+
+	// some feeds get served as text/html, so try parsing before treating the response as a page
+	my_rss, parse_err := parseFeed(result.ContentType, result.Body)
+	if parse_err == nil {
+		cleanRSSData(my_rss)
+		return []discoveredFeed{{URL: pageURL, Title: my_rss.Channel.Title}}, nil
+	}
+	if !isHTMLPage(result.ContentType, result.Body) {
+		return nil, &FetchError{StatusCode: result.StatusCode, Err: fmt.Errorf("%w: %v", ErrNotAFeed, parse_err)}
+	}
+
+	return findFeedLinks(result.Body, result.FinalURL)
+}
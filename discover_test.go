@@ -0,0 +1,28 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFindFeedLinks(t *testing.T) {
+	page := []byte(`<html><head>
+<link rel="stylesheet" href="/style.css">
+<link rel="alternate" type="application/rss+xml" title="Posts" href="/feed.xml">
+<LINK REL="Alternate" TYPE="application/atom+xml; charset=utf-8" href='https://other.example/atom'>
+<link rel="alternate" type="text/html" href="/fr/">
+<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+</head></html>`)
+
+	got, err := findFeedLinks(page, "https://blog.example/posts/")
+	if err != nil {
+		t.Fatalf("findFeedLinks() error = %v", err)
+	}
+	want := []discoveredFeed{
+		{URL: "https://blog.example/feed.xml", Title: "Posts", Type: "application/rss+xml"},
+		{URL: "https://other.example/atom", Type: "application/atom+xml"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("findFeedLinks() = %+v, want %+v", got, want)
+	}
+}
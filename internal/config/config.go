@@ -5,11 +5,120 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 )
 
+// Default cap on how many bytes of a feed response are read when max_feed_bytes isn't set.
+const DefaultMaxFeedBytes = 10 << 20
+
+// Default number of consecutive fetches that must permanently redirect to the same place before a feeds url is
+// rewritten, used when redirect_threshold isn't set.
+const DefaultRedirectThreshold = 3
+
+// Default bounds for the polling interval the scheduler learns from a feeds posting cadence.
+const (
+	DefaultMinFetchInterval = 15 * time.Minute
+	DefaultMaxFetchInterval = 24 * time.Hour
+)
+
+// Defaults for how failing feeds are retried. Retries back off exponentially up to the maximum and a feed is
+// disabled after the maximum number of consecutive failures.
+const (
+	DefaultMaxFetchFailures = 10
+	DefaultMaxRetryBackoff  = 24 * time.Hour
+)
+
+// Default time agg gives running fetches to finish after being asked to stop.
+const DefaultShutdownGracePeriod = 30 * time.Second
+
 type Config struct {
-	DbURL           string `json:"db_url"`
-	CurrentUserName string `json:"current_user_name"`
+	DbURL             string `json:"db_url"`
+	CurrentUserName   string `json:"current_user_name"`
+	MaxFeedBytes      int64  `json:"max_feed_bytes,omitempty"`
+	RedirectThreshold int    `json:"redirect_threshold,omitempty"`
+	MinFetchInterval  string `json:"min_fetch_interval,omitempty"`
+	MaxFetchInterval  string `json:"max_fetch_interval,omitempty"`
+	MaxFetchFailures  int    `json:"max_fetch_failures,omitempty"`
+	MaxRetryBackoff   string `json:"max_retry_backoff,omitempty"`
+	ShutdownGrace     string `json:"shutdown_grace_period,omitempty"`
+	RetentionMaxPosts int    `json:"retention_max_posts,omitempty"`
+	RetentionMaxAge   string `json:"retention_max_age,omitempty"`
+	PruneInterval     string `json:"prune_interval,omitempty"`
+}
+
+// Returns the configured maximum feed response size, falling back to the default when unset.
+func (c *Config) FeedSizeLimit() int64 {
+	if c.MaxFeedBytes <= 0 {
+		return DefaultMaxFeedBytes
+	}
+	return c.MaxFeedBytes
+}
+
+// Returns how many consistent permanent redirects are needed before a feeds url is updated.
+func (c *Config) RedirectsBeforeMove() int {
+	if c.RedirectThreshold <= 0 {
+		return DefaultRedirectThreshold
+	}
+	return c.RedirectThreshold
+}
+
+// Returns the bounds for learned fetch intervals. Missing or unparseable values fall back to the defaults.
+func (c *Config) FetchIntervalBounds() (time.Duration, time.Duration) {
+	min_interval, err := time.ParseDuration(c.MinFetchInterval)
+	if err != nil || min_interval <= 0 {
+		min_interval = DefaultMinFetchInterval
+	}
+	max_interval, err := time.ParseDuration(c.MaxFetchInterval)
+	if err != nil || max_interval < min_interval {
+		max_interval = max(DefaultMaxFetchInterval, min_interval)
+	}
+	return min_interval, max_interval
+}
+
+// Returns how many consecutive failures disable a feed.
+func (c *Config) FailuresBeforeDisable() int {
+	if c.MaxFetchFailures <= 0 {
+		return DefaultMaxFetchFailures
+	}
+	return c.MaxFetchFailures
+}
+
+// Returns the longest a failing feed waits before being retried.
+func (c *Config) RetryBackoffLimit() time.Duration {
+	backoff, err := time.ParseDuration(c.MaxRetryBackoff)
+	if err != nil || backoff <= 0 {
+		return DefaultMaxRetryBackoff
+	}
+	return backoff
+}
+
+// Returns how long running fetches may take to finish once agg has been asked to stop.
+func (c *Config) ShutdownGracePeriod() time.Duration {
+	grace, err := time.ParseDuration(c.ShutdownGrace)
+	if err != nil || grace < 0 {
+		return DefaultShutdownGracePeriod
+	}
+	return grace
+}
+
+// Returns how many posts to keep per feed and how old posts may get before being pruned. Zero means no limit, so
+// posts are kept forever unless retention_max_posts or retention_max_age is set.
+func (c *Config) RetentionLimits() (int, time.Duration) {
+	max_posts := max(c.RetentionMaxPosts, 0)
+	max_age, err := time.ParseDuration(c.RetentionMaxAge)
+	if err != nil || max_age < 0 {
+		max_age = 0
+	}
+	return max_posts, max_age
+}
+
+// Returns how often agg prunes posts, or zero when prune_interval isn't set and agg doesn't prune.
+func (c *Config) AggPruneInterval() time.Duration {
+	interval, err := time.ParseDuration(c.PruneInterval)
+	if err != nil || interval < 0 {
+		return 0
+	}
+	return interval
 }
 
 func CreateConfigFile(url string) error {
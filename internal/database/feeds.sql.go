@@ -7,13 +7,14 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const addFeed = `-- name: AddFeed :one
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id FROM feeds WHERE "name" = $1 and "url" = $2
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, redirect_url, redirect_count, previous_url, fetch_interval_seconds, next_fetch_at, scheduled_interval_seconds, schedule_reason, ttl_minutes, skip_hours, skip_days, update_period, update_frequency, consecutive_failures, last_error, disabled_at, retention_max_posts, retention_max_age_seconds FROM feeds WHERE "name" = $1 and "url" = $2
 `
 
 type AddFeedParams struct {
@@ -32,10 +33,143 @@ func (q *Queries) AddFeed(ctx context.Context, arg AddFeedParams) (Feed, error)
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.PreviousUrl,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ScheduledIntervalSeconds,
+		&i.ScheduleReason,
+		&i.TtlMinutes,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.UpdatePeriod,
+		&i.UpdateFrequency,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.DisabledAt,
+		&i.RetentionMaxPosts,
+		&i.RetentionMaxAgeSeconds,
 	)
 	return i, err
 }
 
+const claimFeed = `-- name: ClaimFeed :one
+UPDATE feeds
+SET last_fetched_at = NOW(),
+    next_fetch_at = NOW() + (COALESCE(fetch_interval_seconds, scheduled_interval_seconds, $1::integer) * INTERVAL '1 second'),
+    updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, redirect_url, redirect_count, previous_url, fetch_interval_seconds, next_fetch_at, scheduled_interval_seconds, schedule_reason, ttl_minutes, skip_hours, skip_days, update_period, update_frequency, consecutive_failures, last_error, disabled_at, retention_max_posts, retention_max_age_seconds
+`
+
+type ClaimFeedParams struct {
+	DefaultIntervalSeconds int32
+	ID                     uuid.UUID
+}
+
+func (q *Queries) ClaimFeed(ctx context.Context, arg ClaimFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, claimFeed, arg.DefaultIntervalSeconds, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.PreviousUrl,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ScheduledIntervalSeconds,
+		&i.ScheduleReason,
+		&i.TtlMinutes,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.UpdatePeriod,
+		&i.UpdateFrequency,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.DisabledAt,
+		&i.RetentionMaxPosts,
+		&i.RetentionMaxAgeSeconds,
+	)
+	return i, err
+}
+
+const claimNextFeedToFetch = `-- name: ClaimNextFeedToFetch :one
+UPDATE feeds
+SET last_fetched_at = NOW(),
+    next_fetch_at = NOW() + (COALESCE(fetch_interval_seconds, scheduled_interval_seconds, $1::integer) * INTERVAL '1 second'),
+    updated_at = NOW()
+WHERE id = (
+    SELECT id FROM feeds
+    WHERE disabled_at IS NULL
+        AND (next_fetch_at IS NULL OR next_fetch_at <= $2::timestamptz)
+    ORDER BY next_fetch_at NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, redirect_url, redirect_count, previous_url, fetch_interval_seconds, next_fetch_at, scheduled_interval_seconds, schedule_reason, ttl_minutes, skip_hours, skip_days, update_period, update_frequency, consecutive_failures, last_error, disabled_at, retention_max_posts, retention_max_age_seconds
+`
+
+type ClaimNextFeedToFetchParams struct {
+	DefaultIntervalSeconds int32
+	DueBy                  time.Time
+}
+
+func (q *Queries) ClaimNextFeedToFetch(ctx context.Context, arg ClaimNextFeedToFetchParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, claimNextFeedToFetch, arg.DefaultIntervalSeconds, arg.DueBy)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.PreviousUrl,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ScheduledIntervalSeconds,
+		&i.ScheduleReason,
+		&i.TtlMinutes,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.UpdatePeriod,
+		&i.UpdateFrequency,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.DisabledAt,
+		&i.RetentionMaxPosts,
+		&i.RetentionMaxAgeSeconds,
+	)
+	return i, err
+}
+
+const clearFeedRedirect = `-- name: ClearFeedRedirect :exec
+UPDATE feeds
+SET redirect_url = NULL, redirect_count = 0, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) ClearFeedRedirect(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, clearFeedRedirect, id)
+	return err
+}
+
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds(id, created_at, updated_at, "name", "url", user_id)
 VALUES (
@@ -46,7 +180,7 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, redirect_url, redirect_count, previous_url, fetch_interval_seconds, next_fetch_at, scheduled_interval_seconds, schedule_reason, ttl_minutes, skip_hours, skip_days, update_period, update_frequency, consecutive_failures, last_error, disabled_at, retention_max_posts, retention_max_age_seconds
 `
 
 type CreateFeedParams struct {
@@ -76,12 +210,125 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.PreviousUrl,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ScheduledIntervalSeconds,
+		&i.ScheduleReason,
+		&i.TtlMinutes,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.UpdatePeriod,
+		&i.UpdateFrequency,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.DisabledAt,
+		&i.RetentionMaxPosts,
+		&i.RetentionMaxAgeSeconds,
+	)
+	return i, err
+}
+
+const getBrokenFeeds = `-- name: GetBrokenFeeds :many
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, redirect_url, redirect_count, previous_url, fetch_interval_seconds, next_fetch_at, scheduled_interval_seconds, schedule_reason, ttl_minutes, skip_hours, skip_days, update_period, update_frequency, consecutive_failures, last_error, disabled_at, retention_max_posts, retention_max_age_seconds FROM feeds
+WHERE consecutive_failures > 0 OR disabled_at IS NOT NULL
+ORDER BY disabled_at NULLS LAST, consecutive_failures DESC
+`
+
+func (q *Queries) GetBrokenFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getBrokenFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.LastFetchedAt,
+			&i.UserID,
+			&i.Etag,
+			&i.LastModified,
+			&i.RedirectUrl,
+			&i.RedirectCount,
+			&i.PreviousUrl,
+			&i.FetchIntervalSeconds,
+			&i.NextFetchAt,
+			&i.ScheduledIntervalSeconds,
+			&i.ScheduleReason,
+			&i.TtlMinutes,
+			&i.SkipHours,
+			&i.SkipDays,
+			&i.UpdatePeriod,
+			&i.UpdateFrequency,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.DisabledAt,
+			&i.RetentionMaxPosts,
+			&i.RetentionMaxAgeSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedByID = `-- name: GetFeedByID :one
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, redirect_url, redirect_count, previous_url, fetch_interval_seconds, next_fetch_at, scheduled_interval_seconds, schedule_reason, ttl_minutes, skip_hours, skip_days, update_period, update_frequency, consecutive_failures, last_error, disabled_at, retention_max_posts, retention_max_age_seconds FROM feeds WHERE id = $1
+`
+
+func (q *Queries) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByID, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.PreviousUrl,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ScheduledIntervalSeconds,
+		&i.ScheduleReason,
+		&i.TtlMinutes,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.UpdatePeriod,
+		&i.UpdateFrequency,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.DisabledAt,
+		&i.RetentionMaxPosts,
+		&i.RetentionMaxAgeSeconds,
 	)
 	return i, err
 }
 
 const getFeedByUrl = `-- name: GetFeedByUrl :one
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id FROM feeds WHERE "url" = $1
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, redirect_url, redirect_count, previous_url, fetch_interval_seconds, next_fetch_at, scheduled_interval_seconds, schedule_reason, ttl_minutes, skip_hours, skip_days, update_period, update_frequency, consecutive_failures, last_error, disabled_at, retention_max_posts, retention_max_age_seconds FROM feeds WHERE "url" = $1
 `
 
 func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
@@ -95,12 +342,31 @@ func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.PreviousUrl,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ScheduledIntervalSeconds,
+		&i.ScheduleReason,
+		&i.TtlMinutes,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.UpdatePeriod,
+		&i.UpdateFrequency,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.DisabledAt,
+		&i.RetentionMaxPosts,
+		&i.RetentionMaxAgeSeconds,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id FROM feeds
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, redirect_url, redirect_count, previous_url, fetch_interval_seconds, next_fetch_at, scheduled_interval_seconds, schedule_reason, ttl_minutes, skip_hours, skip_days, update_period, update_frequency, consecutive_failures, last_error, disabled_at, retention_max_posts, retention_max_age_seconds FROM feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -120,6 +386,25 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.LastFetchedAt,
 			&i.UserID,
+			&i.Etag,
+			&i.LastModified,
+			&i.RedirectUrl,
+			&i.RedirectCount,
+			&i.PreviousUrl,
+			&i.FetchIntervalSeconds,
+			&i.NextFetchAt,
+			&i.ScheduledIntervalSeconds,
+			&i.ScheduleReason,
+			&i.TtlMinutes,
+			&i.SkipHours,
+			&i.SkipDays,
+			&i.UpdatePeriod,
+			&i.UpdateFrequency,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.DisabledAt,
+			&i.RetentionMaxPosts,
+			&i.RetentionMaxAgeSeconds,
 		); err != nil {
 			return nil, err
 		}
@@ -135,7 +420,7 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id FROM feeds
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, redirect_url, redirect_count, previous_url, fetch_interval_seconds, next_fetch_at, scheduled_interval_seconds, schedule_reason, ttl_minutes, skip_hours, skip_days, update_period, update_frequency, consecutive_failures, last_error, disabled_at, retention_max_posts, retention_max_age_seconds FROM feeds
 ORDER BY last_fetched_at NULLS FIRST
 LIMIT 1
 `
@@ -151,10 +436,56 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.PreviousUrl,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ScheduledIntervalSeconds,
+		&i.ScheduleReason,
+		&i.TtlMinutes,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.UpdatePeriod,
+		&i.UpdateFrequency,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.DisabledAt,
+		&i.RetentionMaxPosts,
+		&i.RetentionMaxAgeSeconds,
 	)
 	return i, err
 }
 
+const getSecondsUntilNextFetch = `-- name: GetSecondsUntilNextFetch :one
+SELECT COALESCE(
+    EXTRACT(EPOCH FROM MIN(COALESCE(next_fetch_at, NOW())) - NOW()),
+    $1::float8
+)::float8 AS seconds_until_next_fetch
+FROM feeds
+WHERE disabled_at IS NULL
+`
+
+func (q *Queries) GetSecondsUntilNextFetch(ctx context.Context, idleSeconds float64) (float64, error) {
+	row := q.db.QueryRowContext(ctx, getSecondsUntilNextFetch, idleSeconds)
+	var seconds_until_next_fetch float64
+	err := row.Scan(&seconds_until_next_fetch)
+	return seconds_until_next_fetch, err
+}
+
+const markFeedDue = `-- name: MarkFeedDue :exec
+UPDATE feeds
+SET next_fetch_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) MarkFeedDue(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markFeedDue, id)
+	return err
+}
+
 const markFeedFetched = `-- name: MarkFeedFetched :exec
 UPDATE feeds
 SET last_fetched_at = NOW(), updated_at = NOW()
@@ -165,3 +496,201 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) error {
 	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
 	return err
 }
+
+const recordFeedFailure = `-- name: RecordFeedFailure :exec
+UPDATE feeds
+SET consecutive_failures = consecutive_failures + 1,
+    last_error = $1,
+    next_fetch_at = NOW() + ($2::integer * INTERVAL '1 second'),
+    disabled_at = CASE WHEN $3::boolean THEN NOW() ELSE disabled_at END,
+    updated_at = NOW()
+WHERE id = $4
+`
+
+type RecordFeedFailureParams struct {
+	LastError    sql.NullString
+	RetrySeconds int32
+	Disable      bool
+	ID           uuid.UUID
+}
+
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedFailure,
+		arg.LastError,
+		arg.RetrySeconds,
+		arg.Disable,
+		arg.ID,
+	)
+	return err
+}
+
+const recordFeedRedirect = `-- name: RecordFeedRedirect :one
+UPDATE feeds
+SET redirect_count = CASE WHEN redirect_url = $2 THEN redirect_count + 1 ELSE 1 END,
+    redirect_url = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING redirect_count
+`
+
+type RecordFeedRedirectParams struct {
+	ID          uuid.UUID
+	RedirectUrl sql.NullString
+}
+
+func (q *Queries) RecordFeedRedirect(ctx context.Context, arg RecordFeedRedirectParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedRedirect, arg.ID, arg.RedirectUrl)
+	var redirect_count int32
+	err := row.Scan(&redirect_count)
+	return redirect_count, err
+}
+
+const reenableFeed = `-- name: ReenableFeed :exec
+UPDATE feeds
+SET consecutive_failures = 0, last_error = NULL, disabled_at = NULL, next_fetch_at = NULL, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) ReenableFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, reenableFeed, id)
+	return err
+}
+
+const resetFeedFailures = `-- name: ResetFeedFailures :exec
+UPDATE feeds
+SET consecutive_failures = 0, last_error = NULL, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) ResetFeedFailures(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, resetFeedFailures, id)
+	return err
+}
+
+const setFeedInterval = `-- name: SetFeedInterval :exec
+UPDATE feeds
+SET fetch_interval_seconds = $2,
+    next_fetch_at = last_fetched_at + ($2 * INTERVAL '1 second'),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedIntervalParams struct {
+	ID                   uuid.UUID
+	FetchIntervalSeconds sql.NullInt32
+}
+
+func (q *Queries) SetFeedInterval(ctx context.Context, arg SetFeedIntervalParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedInterval, arg.ID, arg.FetchIntervalSeconds)
+	return err
+}
+
+const setFeedRetention = `-- name: SetFeedRetention :exec
+UPDATE feeds
+SET retention_max_posts = $2,
+    retention_max_age_seconds = $3,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedRetentionParams struct {
+	ID                     uuid.UUID
+	RetentionMaxPosts      sql.NullInt32
+	RetentionMaxAgeSeconds sql.NullInt32
+}
+
+func (q *Queries) SetFeedRetention(ctx context.Context, arg SetFeedRetentionParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedRetention, arg.ID, arg.RetentionMaxPosts, arg.RetentionMaxAgeSeconds)
+	return err
+}
+
+const updateFeedCacheHeaders = `-- name: UpdateFeedCacheHeaders :exec
+UPDATE feeds
+SET etag = $2, last_modified = $3, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedCacheHeadersParams struct {
+	ID           uuid.UUID
+	Etag         sql.NullString
+	LastModified sql.NullString
+}
+
+func (q *Queries) UpdateFeedCacheHeaders(ctx context.Context, arg UpdateFeedCacheHeadersParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedCacheHeaders, arg.ID, arg.Etag, arg.LastModified)
+	return err
+}
+
+const updateFeedPublisherHints = `-- name: UpdateFeedPublisherHints :exec
+UPDATE feeds
+SET ttl_minutes = $2,
+    skip_hours = $3,
+    skip_days = $4,
+    update_period = $5,
+    update_frequency = $6,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedPublisherHintsParams struct {
+	ID              uuid.UUID
+	TtlMinutes      sql.NullInt32
+	SkipHours       sql.NullString
+	SkipDays        sql.NullString
+	UpdatePeriod    sql.NullString
+	UpdateFrequency sql.NullInt32
+}
+
+func (q *Queries) UpdateFeedPublisherHints(ctx context.Context, arg UpdateFeedPublisherHintsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedPublisherHints,
+		arg.ID,
+		arg.TtlMinutes,
+		arg.SkipHours,
+		arg.SkipDays,
+		arg.UpdatePeriod,
+		arg.UpdateFrequency,
+	)
+	return err
+}
+
+const updateFeedSchedule = `-- name: UpdateFeedSchedule :exec
+UPDATE feeds
+SET scheduled_interval_seconds = $1,
+    schedule_reason = $2,
+    next_fetch_at = last_fetched_at + ($3::integer * INTERVAL '1 second'),
+    updated_at = NOW()
+WHERE id = $4
+`
+
+type UpdateFeedScheduleParams struct {
+	ScheduledIntervalSeconds sql.NullInt32
+	ScheduleReason           sql.NullString
+	DelaySeconds             int32
+	ID                       uuid.UUID
+}
+
+func (q *Queries) UpdateFeedSchedule(ctx context.Context, arg UpdateFeedScheduleParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedSchedule,
+		arg.ScheduledIntervalSeconds,
+		arg.ScheduleReason,
+		arg.DelaySeconds,
+		arg.ID,
+	)
+	return err
+}
+
+const updateFeedUrl = `-- name: UpdateFeedUrl :exec
+UPDATE feeds
+SET previous_url = "url", "url" = $2, redirect_url = NULL, redirect_count = 0, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedUrlParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) UpdateFeedUrl(ctx context.Context, arg UpdateFeedUrlParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedUrl, arg.ID, arg.Url)
+	return err
+}
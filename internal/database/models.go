@@ -12,13 +12,45 @@ import (
 )
 
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	LastFetchedAt sql.NullTime
-	UserID        uuid.UUID
+	ID                       uuid.UUID
+	CreatedAt                time.Time
+	UpdatedAt                time.Time
+	Name                     string
+	Url                      string
+	LastFetchedAt            sql.NullTime
+	UserID                   uuid.UUID
+	Etag                     sql.NullString
+	LastModified             sql.NullString
+	RedirectUrl              sql.NullString
+	RedirectCount            int32
+	PreviousUrl              sql.NullString
+	FetchIntervalSeconds     sql.NullInt32
+	NextFetchAt              sql.NullTime
+	ScheduledIntervalSeconds sql.NullInt32
+	ScheduleReason           sql.NullString
+	TtlMinutes               sql.NullInt32
+	SkipHours                sql.NullString
+	SkipDays                 sql.NullString
+	UpdatePeriod             sql.NullString
+	UpdateFrequency          sql.NullInt32
+	ConsecutiveFailures      int32
+	LastError                sql.NullString
+	DisabledAt               sql.NullTime
+	RetentionMaxPosts        sql.NullInt32
+	RetentionMaxAgeSeconds   sql.NullInt32
+}
+
+type FeedFetch struct {
+	ID                uuid.UUID
+	FeedID            uuid.UUID
+	StartedAt         time.Time
+	FinishedAt        time.Time
+	HttpStatus        sql.NullInt32
+	BytesRead         int64
+	ItemsSeen         int32
+	PostsInserted     int32
+	DuplicatesSkipped int32
+	Error             sql.NullString
 }
 
 type FeedFollow struct {
@@ -30,14 +62,51 @@ type FeedFollow struct {
 }
 
 type Post struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Title        sql.NullString
+	Url          string
+	Description  string
+	PublishedAt  sql.NullTime
+	FeedID       uuid.UUID
+	Author       sql.NullString
+	Guid         sql.NullString
+	DedupeKey    string
+	SearchVector interface{}
+}
+
+type PostRead struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+type PostRevision struct {
 	ID          uuid.UUID
+	PostID      uuid.UUID
 	CreatedAt   time.Time
-	UpdatedAt   time.Time
 	Title       sql.NullString
-	Url         string
 	Description string
 	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+}
+
+type PostStar struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	StarredAt time.Time
+}
+
+type PrunedPost struct {
+	FeedID    uuid.UUID
+	DedupeKey string
+	PrunedAt  time.Time
+}
+
+type SameArticle struct {
+	PostID       uuid.UUID
+	SameAsPostID uuid.UUID
+	CreatedAt    time.Time
 }
 
 type User struct {
@@ -13,8 +13,70 @@ import (
 	"github.com/google/uuid"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts(id, created_at, updated_at, title, "url", "description", published_at, feed_id)
+const countPrunablePosts = `-- name: CountPrunablePosts :many
+WITH ranked AS (
+    SELECT
+        posts.id,
+        posts.feed_id,
+        posts.dedupe_key,
+        ROW_NUMBER() OVER (PARTITION BY posts.feed_id ORDER BY COALESCE(posts.published_at, posts.created_at) DESC, posts.id DESC) AS position,
+        COALESCE(posts.published_at, posts.created_at) AS sort_time,
+        COALESCE(feeds.retention_max_posts, $1::integer) AS max_posts,
+        COALESCE(feeds.retention_max_age_seconds, $2::integer) AS max_age_seconds
+    FROM posts
+    INNER JOIN feeds ON posts.feed_id = feeds.id
+), expired AS (
+    SELECT ranked.id, ranked.feed_id, ranked.dedupe_key
+    FROM ranked
+    WHERE ((ranked.position > ranked.max_posts) OR (ranked.sort_time < NOW() - (ranked.max_age_seconds * INTERVAL '1 second')))
+        AND NOT EXISTS (SELECT 1 FROM post_stars WHERE post_stars.post_id = ranked.id)
+)
+SELECT
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    COUNT(*) AS post_count
+FROM expired
+INNER JOIN feeds ON expired.feed_id = feeds.id
+GROUP BY feeds.id, feeds.name, feeds.url
+ORDER BY feeds.name
+`
+
+type CountPrunablePostsParams struct {
+	DefaultMaxPosts      sql.NullInt32
+	DefaultMaxAgeSeconds sql.NullInt32
+}
+
+type CountPrunablePostsRow struct {
+	FeedName  string
+	FeedUrl   string
+	PostCount int64
+}
+
+func (q *Queries) CountPrunablePosts(ctx context.Context, arg CountPrunablePostsParams) ([]CountPrunablePostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, countPrunablePosts, arg.DefaultMaxPosts, arg.DefaultMaxAgeSeconds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountPrunablePostsRow
+	for rows.Next() {
+		var i CountPrunablePostsRow
+		if err := rows.Scan(&i.FeedName, &i.FeedUrl, &i.PostCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createPost = `-- name: CreatePost :exec
+INSERT INTO posts(id, created_at, updated_at, title, "url", "description", published_at, feed_id, author, guid, dedupe_key)
 VALUES (
     $1,
     $2,
@@ -23,9 +85,11 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9,
+    $10,
+    $11
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
 `
 
 type CreatePostParams struct {
@@ -37,10 +101,13 @@ type CreatePostParams struct {
 	Description string
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Author      sql.NullString
+	Guid        sql.NullString
+	DedupeKey   string
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
+func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) error {
+	_, err := q.db.ExecContext(ctx, createPost,
 		arg.ID,
 		arg.CreatedAt,
 		arg.UpdatedAt,
@@ -49,8 +116,126 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Author,
+		arg.Guid,
+		arg.DedupeKey,
+	)
+	return err
+}
+
+const getGuidlessPostByFeedAndUrl = `-- name: GetGuidlessPostByFeedAndUrl :one
+SELECT id, created_at, updated_at, title, "url", "description", published_at, feed_id, author, guid, dedupe_key
+FROM posts
+WHERE feed_id = $1 AND "url" = $2 AND guid IS NULL
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+type GetGuidlessPostByFeedAndUrlParams struct {
+	FeedID uuid.UUID
+	Url    string
+}
+
+type GetGuidlessPostByFeedAndUrlRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Author      sql.NullString
+	Guid        sql.NullString
+	DedupeKey   string
+}
+
+func (q *Queries) GetGuidlessPostByFeedAndUrl(ctx context.Context, arg GetGuidlessPostByFeedAndUrlParams) (GetGuidlessPostByFeedAndUrlRow, error) {
+	row := q.db.QueryRowContext(ctx, getGuidlessPostByFeedAndUrl, arg.FeedID, arg.Url)
+	var i GetGuidlessPostByFeedAndUrlRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Author,
+		&i.Guid,
+		&i.DedupeKey,
+	)
+	return i, err
+}
+
+const getPostByDedupeKey = `-- name: GetPostByDedupeKey :one
+SELECT id, created_at, updated_at, title, "url", "description", published_at, feed_id, author, guid, dedupe_key
+FROM posts
+WHERE feed_id = $1 AND dedupe_key = $2
+`
+
+type GetPostByDedupeKeyParams struct {
+	FeedID    uuid.UUID
+	DedupeKey string
+}
+
+type GetPostByDedupeKeyRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Author      sql.NullString
+	Guid        sql.NullString
+	DedupeKey   string
+}
+
+func (q *Queries) GetPostByDedupeKey(ctx context.Context, arg GetPostByDedupeKeyParams) (GetPostByDedupeKeyRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostByDedupeKey, arg.FeedID, arg.DedupeKey)
+	var i GetPostByDedupeKeyRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Author,
+		&i.Guid,
+		&i.DedupeKey,
 	)
-	var i Post
+	return i, err
+}
+
+const getPostByID = `-- name: GetPostByID :one
+SELECT id, created_at, updated_at, title, "url", "description", published_at, feed_id, author, guid, dedupe_key
+FROM posts
+WHERE id = $1
+`
+
+type GetPostByIDRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Author      sql.NullString
+	Guid        sql.NullString
+	DedupeKey   string
+}
+
+func (q *Queries) GetPostByID(ctx context.Context, id uuid.UUID) (GetPostByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostByID, id)
+	var i GetPostByIDRow
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
@@ -60,25 +245,130 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Author,
+		&i.Guid,
+		&i.DedupeKey,
 	)
 	return i, err
 }
 
+const getPostsByUrl = `-- name: GetPostsByUrl :many
+SELECT id, created_at, updated_at, title, "url", "description", published_at, feed_id, author, guid, dedupe_key
+FROM posts
+WHERE "url" = $1
+ORDER BY created_at
+`
+
+type GetPostsByUrlRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Author      sql.NullString
+	Guid        sql.NullString
+	DedupeKey   string
+}
+
+func (q *Queries) GetPostsByUrl(ctx context.Context, url string) ([]GetPostsByUrlRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsByUrl, url)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostsByUrlRow
+	for rows.Next() {
+		var i GetPostsByUrlRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Author,
+			&i.Guid,
+			&i.DedupeKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT id, created_at, updated_at, title, url, description, published_at, feed_id FROM posts
-ORDER BY published_at DESC
-LIMIT $1
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts."url", posts."description", posts.published_at, posts.feed_id, posts.author, posts.guid, posts.dedupe_key,
+    feeds.name AS feed_name
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+    AND ($2::text IS NULL OR feeds.url = $2)
+    AND ($3::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= $3)
+    AND ($4::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < $4)
+    AND ($5::timestamp IS NULL OR (COALESCE(posts.published_at, posts.created_at), posts.id) < ($5, $6::uuid))
+    AND (NOT $7::boolean OR NOT EXISTS (SELECT 1 FROM post_reads WHERE post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id))
+ORDER BY COALESCE(posts.published_at, posts.created_at) DESC, posts.id DESC
+LIMIT $8
 `
 
-func (q *Queries) GetPostsForUser(ctx context.Context, limit int32) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, limit)
+type GetPostsForUserParams struct {
+	UserID     uuid.UUID
+	FeedUrl    sql.NullString
+	Since      sql.NullTime
+	Before     sql.NullTime
+	AfterTime  sql.NullTime
+	AfterID    uuid.NullUUID
+	UnreadOnly bool
+	Limit      int32
+}
+
+type GetPostsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Author      sql.NullString
+	Guid        sql.NullString
+	DedupeKey   string
+	FeedName    string
+}
+
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.FeedUrl,
+		arg.Since,
+		arg.Before,
+		arg.AfterTime,
+		arg.AfterID,
+		arg.UnreadOnly,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetPostsForUserRow
 	for rows.Next() {
-		var i Post
+		var i GetPostsForUserRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
@@ -88,6 +378,10 @@ func (q *Queries) GetPostsForUser(ctx context.Context, limit int32) ([]Post, err
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Author,
+			&i.Guid,
+			&i.DedupeKey,
+			&i.FeedName,
 		); err != nil {
 			return nil, err
 		}
@@ -101,3 +395,262 @@ func (q *Queries) GetPostsForUser(ctx context.Context, limit int32) ([]Post, err
 	}
 	return items, nil
 }
+
+const getRecentPublishTimes = `-- name: GetRecentPublishTimes :many
+SELECT published_at FROM posts
+WHERE feed_id = $1 AND published_at IS NOT NULL
+ORDER BY published_at DESC
+LIMIT $2
+`
+
+type GetRecentPublishTimesParams struct {
+	FeedID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetRecentPublishTimes(ctx context.Context, arg GetRecentPublishTimesParams) ([]sql.NullTime, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentPublishTimes, arg.FeedID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []sql.NullTime
+	for rows.Next() {
+		var published_at sql.NullTime
+		if err := rows.Scan(&published_at); err != nil {
+			return nil, err
+		}
+		items = append(items, published_at)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isPostPruned = `-- name: IsPostPruned :one
+SELECT EXISTS (
+    SELECT 1 FROM pruned_posts
+    WHERE feed_id = $1 AND dedupe_key = $2
+)
+`
+
+type IsPostPrunedParams struct {
+	FeedID    uuid.UUID
+	DedupeKey string
+}
+
+func (q *Queries) IsPostPruned(ctx context.Context, arg IsPostPrunedParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isPostPruned, arg.FeedID, arg.DedupeKey)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const linkSameArticle = `-- name: LinkSameArticle :exec
+INSERT INTO same_articles(post_id, same_as_post_id, created_at)
+SELECT $1::uuid, id, NOW()
+FROM posts
+WHERE "url" = $2 AND feed_id <> $3
+ON CONFLICT DO NOTHING
+`
+
+type LinkSameArticleParams struct {
+	PostID uuid.UUID
+	Url    string
+	FeedID uuid.UUID
+}
+
+func (q *Queries) LinkSameArticle(ctx context.Context, arg LinkSameArticleParams) error {
+	_, err := q.db.ExecContext(ctx, linkSameArticle, arg.PostID, arg.Url, arg.FeedID)
+	return err
+}
+
+const prunePosts = `-- name: PrunePosts :many
+WITH ranked AS (
+    SELECT
+        posts.id,
+        posts.feed_id,
+        posts.dedupe_key,
+        ROW_NUMBER() OVER (PARTITION BY posts.feed_id ORDER BY COALESCE(posts.published_at, posts.created_at) DESC, posts.id DESC) AS position,
+        COALESCE(posts.published_at, posts.created_at) AS sort_time,
+        COALESCE(feeds.retention_max_posts, $1::integer) AS max_posts,
+        COALESCE(feeds.retention_max_age_seconds, $2::integer) AS max_age_seconds
+    FROM posts
+    INNER JOIN feeds ON posts.feed_id = feeds.id
+), expired AS (
+    SELECT ranked.id, ranked.feed_id, ranked.dedupe_key
+    FROM ranked
+    WHERE ((ranked.position > ranked.max_posts) OR (ranked.sort_time < NOW() - (ranked.max_age_seconds * INTERVAL '1 second')))
+        AND NOT EXISTS (SELECT 1 FROM post_stars WHERE post_stars.post_id = ranked.id)
+), deleted AS (
+    DELETE FROM posts
+    WHERE posts.id IN (SELECT expired.id FROM expired)
+    RETURNING posts.feed_id, posts.dedupe_key
+), tombstoned AS (
+    INSERT INTO pruned_posts(feed_id, dedupe_key, pruned_at)
+    SELECT deleted.feed_id, deleted.dedupe_key, NOW()
+    FROM deleted
+    ON CONFLICT (feed_id, dedupe_key) DO UPDATE SET pruned_at = EXCLUDED.pruned_at
+)
+SELECT
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    COUNT(*) AS post_count
+FROM deleted
+INNER JOIN feeds ON deleted.feed_id = feeds.id
+GROUP BY feeds.id, feeds.name, feeds.url
+ORDER BY feeds.name
+`
+
+type PrunePostsParams struct {
+	DefaultMaxPosts      sql.NullInt32
+	DefaultMaxAgeSeconds sql.NullInt32
+}
+
+type PrunePostsRow struct {
+	FeedName  string
+	FeedUrl   string
+	PostCount int64
+}
+
+func (q *Queries) PrunePosts(ctx context.Context, arg PrunePostsParams) ([]PrunePostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, prunePosts, arg.DefaultMaxPosts, arg.DefaultMaxAgeSeconds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PrunePostsRow
+	for rows.Next() {
+		var i PrunePostsRow
+		if err := rows.Scan(&i.FeedName, &i.FeedUrl, &i.PostCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchPostsForUser = `-- name: SearchPostsForUser :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    feeds.name AS feed_name,
+    ts_rank(posts.search_vector, websearch_to_tsquery('english', $1)) AS rank,
+    ts_headline('english', regexp_replace(posts.description, '<[^>]*>', ' ', 'g'), websearch_to_tsquery('english', $1), 'MaxFragments=2, MaxWords=20, MinWords=5, StartSel=*, StopSel=*') AS snippet
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE feed_follows.user_id = $2
+    AND posts.search_vector @@ websearch_to_tsquery('english', $1)
+    AND ($3::text IS NULL OR feeds.url = $3)
+    AND ($4::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= $4)
+    AND ($5::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < $5)
+ORDER BY rank DESC, COALESCE(posts.published_at, posts.created_at) DESC
+LIMIT $6
+`
+
+type SearchPostsForUserParams struct {
+	Query   string
+	UserID  uuid.UUID
+	FeedUrl sql.NullString
+	Since   sql.NullTime
+	Before  sql.NullTime
+	Limit   int32
+}
+
+type SearchPostsForUserRow struct {
+	ID          uuid.UUID
+	Title       sql.NullString
+	Url         string
+	PublishedAt sql.NullTime
+	FeedName    string
+	Rank        float32
+	Snippet     string
+}
+
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser,
+		arg.Query,
+		arg.UserID,
+		arg.FeedUrl,
+		arg.Since,
+		arg.Before,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsForUserRow
+	for rows.Next() {
+		var i SearchPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.Rank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updatePostContent = `-- name: UpdatePostContent :exec
+UPDATE posts
+SET title = $2,
+    "description" = $3,
+    published_at = $4,
+    author = $5,
+    updated_at = $6,
+    dedupe_key = $7,
+    guid = $8
+WHERE id = $1
+`
+
+type UpdatePostContentParams struct {
+	ID          uuid.UUID
+	Title       sql.NullString
+	Description string
+	PublishedAt sql.NullTime
+	Author      sql.NullString
+	UpdatedAt   time.Time
+	DedupeKey   string
+	Guid        sql.NullString
+}
+
+func (q *Queries) UpdatePostContent(ctx context.Context, arg UpdatePostContentParams) error {
+	_, err := q.db.ExecContext(ctx, updatePostContent,
+		arg.ID,
+		arg.Title,
+		arg.Description,
+		arg.PublishedAt,
+		arg.Author,
+		arg.UpdatedAt,
+		arg.DedupeKey,
+		arg.Guid,
+	)
+	return err
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cbrookscode/blog_aggregator/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// What storePost did with an item
+type postOutcome int
+
+const (
+	postInserted postOutcome = iota
+	postUpdated
+	postUnchanged
+)
+
+// Builds the key a post is deduplicated on within its feed: the item's guid when it has one, otherwise a hash of
+// its url and title. 014_post_guids.sql backfills existing posts with the same hash, so keep the two in step.
+func postDedupeKey(guid, url, title string) string {
+	if guid != "" {
+		return "guid:" + guid
+	}
+	sum := sha256.Sum256([]byte(url + "\n" + title))
+	return "hash:" + hex.EncodeToString(sum[:])
+}
+
+// Stores a feed item as a post. Items the feed has already given us are updated in place when their title,
+// description or published date changed, and the version they replace is kept in post_revisions. Items are
+// matched on their dedupe key first. When that misses they are matched on url against posts without a guid, so
+// posts stored before guids were tracked pick up the item's guid instead of being stored twice, and a corrected
+// title on an item without a guid is seen as an edit rather than a new post. Items without a guid are only matched
+// on url when url_is_unique says no other item in the fetch has the same url, otherwise items that share a url
+// would keep overwriting each other. New posts are linked to posts from other feeds with the same url.
+func storePost(ctx context.Context, s *state, params database.CreatePostParams, url_is_unique bool) (postOutcome, error) {
+	existing, err := s.db.GetPostByDedupeKey(
+		ctx,
+		database.GetPostByDedupeKeyParams{
+			FeedID:    params.FeedID,
+			DedupeKey: params.DedupeKey,
+		},
+	)
+	if err == sql.ErrNoRows && (params.Guid.Valid || url_is_unique) {
+		var by_url database.GetGuidlessPostByFeedAndUrlRow
+		by_url, err = s.db.GetGuidlessPostByFeedAndUrl(
+			ctx,
+			database.GetGuidlessPostByFeedAndUrlParams{
+				FeedID: params.FeedID,
+				Url:    params.Url,
+			},
+		)
+		existing = database.GetPostByDedupeKeyRow(by_url)
+	}
+	if err == sql.ErrNoRows {
+		// items the retention policy already pruned would otherwise come back as new posts on every fetch
+		pruned, err := s.db.IsPostPruned(
+			ctx,
+			database.IsPostPrunedParams{
+				FeedID:    params.FeedID,
+				DedupeKey: params.DedupeKey,
+			},
+		)
+		if err != nil {
+			return 0, fmt.Errorf("error checking for pruned post: %w", err)
+		}
+		if pruned {
+			return postUnchanged, nil
+		}
+
+		err = s.db.CreatePost(ctx, params)
+		if err != nil {
+			// another worker stored the same item first
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == "posts_feed_id_dedupe_key_key" {
+				return postUnchanged, nil
+			}
+			return 0, fmt.Errorf("error creating post: %w", err)
+		}
+		err = s.db.LinkSameArticle(
+			ctx,
+			database.LinkSameArticleParams{
+				PostID: params.ID,
+				Url:    params.Url,
+				FeedID: params.FeedID,
+			},
+		)
+		if err != nil {
+			fmt.Printf("error linking post %v to other feeds: %v\n", params.Url, err)
+		}
+		return postInserted, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error getting existing post: %w", err)
+	}
+
+	if !postChanged(existing, params) {
+		// authors and guids aren't revisioned, so they are updated without keeping the old values
+		if existing.Author != params.Author || existing.Guid != params.Guid || existing.DedupeKey != params.DedupeKey {
+			err = s.db.UpdatePostContent(ctx, updatePostParams(existing.ID, params))
+			if err != nil {
+				return 0, fmt.Errorf("error updating post: %w", err)
+			}
+		}
+		return postUnchanged, nil
+	}
+
+	err = s.db.CreatePostRevision(
+		ctx,
+		database.CreatePostRevisionParams{
+			ID:          uuid.New(),
+			PostID:      existing.ID,
+			CreatedAt:   time.Now().UTC(),
+			Title:       existing.Title,
+			Description: existing.Description,
+			PublishedAt: existing.PublishedAt,
+		},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error storing post revision: %w", err)
+	}
+	err = s.db.UpdatePostContent(ctx, updatePostParams(existing.ID, params))
+	if err != nil {
+		return 0, fmt.Errorf("error updating post: %w", err)
+	}
+	return postUpdated, nil
+}
+
+// Reports whether the item's title, description or published date differ from the stored post
+func postChanged(existing database.GetPostByDedupeKeyRow, params database.CreatePostParams) bool {
+	if existing.Title != params.Title || existing.Description != params.Description {
+		return true
+	}
+	return !sameNullTime(existing.PublishedAt, params.PublishedAt)
+}
+
+func sameNullTime(a, b sql.NullTime) bool {
+	if a.Valid != b.Valid {
+		return false
+	}
+	return !a.Valid || a.Time.Equal(b.Time)
+}
+
+func formatPublishedAt(t sql.NullTime) string {
+	if !t.Valid {
+		return "unknown"
+	}
+	return t.Time.Format(time.RFC1123)
+}
+
+func updatePostParams(id uuid.UUID, params database.CreatePostParams) database.UpdatePostContentParams {
+	return database.UpdatePostContentParams{
+		ID:          id,
+		Title:       params.Title,
+		Description: params.Description,
+		PublishedAt: params.PublishedAt,
+		Author:      params.Author,
+		UpdatedAt:   time.Now().UTC(),
+		DedupeKey:   params.DedupeKey,
+		Guid:        params.Guid,
+	}
+}
+
+// Timelines are sorted newest first on a post's published date, or when we stored it if it has none, then on id.
+func postSortTime(published_at sql.NullTime, created_at time.Time) time.Time {
+	if published_at.Valid {
+		return published_at.Time
+	}
+	return created_at
+}
+
+// Encodes the sort position of the last post on a page into an opaque token that fetches the page after it.
+func encodePostCursor(sort_time time.Time, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(sort_time.UTC().Format(time.RFC3339Nano) + "|" + id.String()))
+}
+
+func decodePostCursor(cursor string) (time.Time, uuid.UUID, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+	sort_value, id_value, found := strings.Cut(string(decoded), "|")
+	if !found {
+		return time.Time{}, uuid.UUID{}, fmt.Errorf("invalid cursor")
+	}
+	sort_time, err := time.Parse(time.RFC3339Nano, sort_value)
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, fmt.Errorf("invalid cursor time: %w", err)
+	}
+	id, err := uuid.Parse(id_value)
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, fmt.Errorf("invalid cursor id: %w", err)
+	}
+	return sort_time, id, nil
+}
+
+// Line by line diff of two texts. Unchanged lines are prefixed with two spaces, removed lines with "- " and added
+// lines with "+ ".
+func diffLines(before, after string) []string {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := []string{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, "+ "+b[j])
+	}
+	return lines
+}
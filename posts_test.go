@@ -0,0 +1,43 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines("a\nb\nc", "a\nx\nc\nd")
+	want := []string{"  a", "- b", "+ x", "  c", "+ d"}
+	if !slices.Equal(got, want) {
+		t.Errorf("diffLines() = %q, want %q", got, want)
+	}
+
+	got = diffLines("same", "same")
+	if !slices.Equal(got, []string{"  same"}) {
+		t.Errorf("diffLines() of equal texts = %q", got)
+	}
+}
+
+func TestPostCursorRoundTrip(t *testing.T) {
+	sort_time := time.Date(2024, time.March, 5, 14, 30, 0, 123456000, time.UTC)
+	id := uuid.New()
+
+	got_time, got_id, err := decodePostCursor(encodePostCursor(sort_time, id))
+	if err != nil {
+		t.Fatalf("decodePostCursor() error = %v", err)
+	}
+	if !got_time.Equal(sort_time) || got_id != id {
+		t.Errorf("decodePostCursor() = %v, %v, want %v, %v", got_time, got_id, sort_time, id)
+	}
+}
+
+func TestDecodePostCursorRejectsBadTokens(t *testing.T) {
+	for _, cursor := range []string{"", "!!!", "bm8tc2VwYXJhdG9y", "bm90LWEtdGltZXxub3QtYS11dWlk"} {
+		if _, _, err := decodePostCursor(cursor); err == nil {
+			t.Errorf("decodePostCursor(%q) returned no error", cursor)
+		}
+	}
+}
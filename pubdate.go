@@ -0,0 +1,119 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Offsets for the zone abbreviations feeds commonly use. time.Parse records unknown abbreviations with a zero
+// offset, so these are swapped for numeric offsets before parsing.
+var zoneOffsets = map[string]string{
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"HST":  "-1000",
+	"BST":  "+0100",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"JST":  "+0900",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+}
+
+var weekdayNames = map[string]bool{
+	"mon": true, "tue": true, "wed": true, "thu": true, "fri": true, "sat": true, "sun": true,
+	"monday": true, "tuesday": true, "wednesday": true, "thursday": true, "friday": true, "saturday": true, "sunday": true,
+}
+
+var colonOffset = regexp.MustCompile(`^([+-]\d\d):(\d\d)$`)
+
+// Layouts tried in order once a date string has been normalized. Day and hour fields without a leading zero
+// accept one or two digits, which covers feeds that write "3 Feb" or "9:05".
+var pubDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 January 2006 15:04:05 -0700",
+	"2 January 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006",
+	"Jan 2 2006 15:04:05 -0700",
+	"Jan 2 15:04:05 -0700 2006",
+	"Jan 2 15:04:05 2006",
+	time.Layout,
+}
+
+// Cleans up the quirks seen in real feeds so the layouts above have a chance of matching: extra whitespace,
+// leading weekday names, named zones, and "+01:00" offsets in RFC1123 style dates.
+func normalizePubDate(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	// drop a leading weekday, with or without its trailing comma
+	first := strings.ToLower(strings.TrimSuffix(fields[0], ","))
+	if weekdayNames[first] {
+		fields = fields[1:]
+	}
+	for i := 0; i < len(fields); i++ {
+		fields[i] = strings.TrimSuffix(fields[i], ",")
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+
+	last := len(fields) - 1
+	if offset, ok := zoneOffsets[strings.ToUpper(fields[last])]; ok && len(fields) > 1 {
+		fields[last] = offset
+	} else if match := colonOffset.FindStringSubmatch(fields[last]); match != nil && len(fields) > 1 {
+		fields[last] = match[1] + match[2]
+	} else if last > 0 && len(fields) == 5 {
+		// unix date style puts the zone before the year, e.g. "Jan 2 15:04:05 MST 2006"
+		if offset, ok := zoneOffsets[strings.ToUpper(fields[last-1])]; ok {
+			fields[last-1] = offset
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// Parses the publish date of a feed item. Returns false when the value is empty or in a format we don't
+// recognise so the post can be stored with a NULL published_at instead of failing.
+func parsePublishedAt(value string) (time.Time, bool) {
+	normalized := normalizePubDate(value)
+	if normalized == "" {
+		return time.Time{}, false
+	}
+
+	for i := 0; i < len(pubDateLayouts); i++ {
+		parsed_time, err := time.Parse(pubDateLayouts[i], normalized)
+		if err == nil {
+			return parsed_time.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePublishedAt(t *testing.T) {
+	want := time.Date(2024, time.March, 5, 14, 30, 0, 0, time.UTC)
+	cases := []struct {
+		name  string
+		value string
+		want  time.Time
+	}{
+		{"RFC1123", "Tue, 05 Mar 2024 14:30:00 GMT", want},
+		{"RFC1123Z", "Tue, 05 Mar 2024 14:30:00 +0000", want},
+		{"RFC3339", "2024-03-05T14:30:00Z", want},
+		{"RFC3339 with offset", "2024-03-05T16:30:00+02:00", want},
+		{"RFC3339 fractional seconds", "2024-03-05T14:30:00.000Z", want},
+		{"named zone EST", "Tue, 05 Mar 2024 09:30:00 EST", want},
+		{"named zone PDT", "Tue, 05 Mar 2024 07:30:00 PDT", want},
+		{"single digit day", "Tue, 5 Mar 2024 14:30:00 GMT", want},
+		{"missing seconds", "Tue, 05 Mar 2024 14:30 GMT", want},
+		{"missing weekday", "05 Mar 2024 14:30:00 +0000", want},
+		{"colon offset", "Tue, 05 Mar 2024 15:30:00 +01:00", want},
+		{"extra whitespace", "  Tue,  05 Mar 2024 14:30:00 GMT ", want},
+		{"date only", "2024-03-05", time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := parsePublishedAt(c.value)
+			if !ok {
+				t.Fatalf("parsePublishedAt(%q) failed to parse", c.value)
+			}
+			if !got.Equal(c.want) || got.Location() != time.UTC {
+				t.Errorf("parsePublishedAt(%q) = %v, want %v in UTC", c.value, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParsePublishedAtRejectsGarbage(t *testing.T) {
+	for _, value := range []string{"", "   ", "yesterday", "2024-13-45"} {
+		if got, ok := parsePublishedAt(value); ok {
+			t.Errorf("parsePublishedAt(%q) = %v, want no date", value, got)
+		}
+	}
+}
@@ -1,20 +1,39 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	config "github.com/cbrookscode/blog_aggregator/internal/config"
 )
 
+// Scheduling hints publishers can include in their feeds. TTL is in minutes, skip hours are GMT hours (0-23) and
+// skip days are english day names. UpdatePeriod and UpdateFrequency come from the syndication module.
+type feedHints struct {
+	TTL             string   `xml:"ttl"`
+	SkipHours       []string `xml:"skipHours>hour"`
+	SkipDays        []string `xml:"skipDays>day"`
+	UpdatePeriod    string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+	UpdateFrequency string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+}
+
 type RSSFeed struct {
 	Channel struct {
 		Title       string    `xml:"title"`
 		Link        string    `xml:"link"`
 		Description string    `xml:"description"`
 		Item        []RSSItem `xml:"item"`
+		feedHints
 	} `xml:"channel"`
 }
 
@@ -23,6 +42,303 @@ type RSSItem struct {
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	Author      string `xml:"author"`
+	Guid        string `xml:"guid"`
+}
+
+// RSS 1.0 documents are rooted at rdf:RDF and keep their items as siblings of the channel rather than inside it.
+type RDFFeed struct {
+	Channel struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+		feedHints
+	} `xml:"channel"`
+	Item []RDFItem `xml:"item"`
+}
+
+type RDFItem struct {
+	About       string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+}
+
+// Maps an rdf feed onto the rss model, using dc:date as the publish time.
+func (r *RDFFeed) toRSS() *RSSFeed {
+	rss := &RSSFeed{}
+	rss.Channel.Title = r.Channel.Title
+	rss.Channel.Link = r.Channel.Link
+	rss.Channel.Description = r.Channel.Description
+	rss.Channel.feedHints = r.Channel.feedHints
+	for i := 0; i < len(r.Item); i++ {
+		rss.Channel.Item = append(rss.Channel.Item, RSSItem{
+			Title:       r.Item[i].Title,
+			Link:        strings.TrimSpace(r.Item[i].Link),
+			Description: r.Item[i].Description,
+			PubDate:     strings.TrimSpace(r.Item[i].Date),
+			Author:      strings.TrimSpace(r.Item[i].Creator),
+			Guid:        strings.TrimSpace(r.Item[i].About),
+		})
+	}
+	return rss
+}
+
+type AtomFeed struct {
+	Title    AtomText    `xml:"title"`
+	Subtitle AtomText    `xml:"subtitle"`
+	Links    []AtomLink  `xml:"link"`
+	Entries  []AtomEntry `xml:"entry"`
+	feedHints
+}
+
+type AtomEntry struct {
+	ID        string       `xml:"id"`
+	Title     AtomText     `xml:"title"`
+	Links     []AtomLink   `xml:"link"`
+	Summary   AtomText     `xml:"summary"`
+	Content   AtomText     `xml:"content"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Authors   []AtomPerson `xml:"author"`
+}
+
+type AtomPerson struct {
+	Name string `xml:"name"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// Atom text constructs can hold plain text, escaped html, or inline xhtml depending on the type attribute.
+type AtomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// Returns the href of the alternate link, falling back to the first link if none is marked alternate.
+func alternateLink(links []AtomLink) string {
+	for i := 0; i < len(links); i++ {
+		if links[i].Rel == "" || links[i].Rel == "alternate" {
+			return links[i].Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+func atomAuthorNames(people []AtomPerson) string {
+	names := []string{}
+	for i := 0; i < len(people); i++ {
+		if name := strings.TrimSpace(people[i].Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// Maps an atom feed onto the rss model so the rest of the app only deals with one feed shape.
+func (a *AtomFeed) toRSS() *RSSFeed {
+	rss := &RSSFeed{}
+	rss.Channel.Title = a.Title.String()
+	rss.Channel.Link = alternateLink(a.Links)
+	rss.Channel.Description = a.Subtitle.String()
+	rss.Channel.feedHints = a.feedHints
+	for i := 0; i < len(a.Entries); i++ {
+		entry := a.Entries[i]
+
+		description := entry.Content.String()
+		if description == "" {
+			description = entry.Summary.String()
+		}
+
+		pub_date := entry.Published
+		if pub_date == "" {
+			pub_date = entry.Updated
+		}
+
+		rss.Channel.Item = append(rss.Channel.Item, RSSItem{
+			Title:       entry.Title.String(),
+			Link:        alternateLink(entry.Links),
+			Description: description,
+			PubDate:     strings.TrimSpace(pub_date),
+			Author:      atomAuthorNames(entry.Authors),
+			Guid:        strings.TrimSpace(entry.ID),
+		})
+	}
+	return rss
+}
+
+// JSON Feed documents as described at https://www.jsonfeed.org/version/1.1/
+type JSONFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	Description string           `json:"description"`
+	Authors     []JSONFeedAuthor `json:"authors"`
+	Author      *JSONFeedAuthor  `json:"author"` // version 1.0 only allowed a single author
+	Items       []JSONFeedItem   `json:"items"`
+}
+
+type JSONFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	ExternalURL   string           `json:"external_url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	ContentText   string           `json:"content_text"`
+	Summary       string           `json:"summary"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Authors       []JSONFeedAuthor `json:"authors"`
+	Author        *JSONFeedAuthor  `json:"author"`
+}
+
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func jsonFeedAuthorNames(authors []JSONFeedAuthor, author *JSONFeedAuthor) string {
+	if len(authors) == 0 && author != nil {
+		authors = []JSONFeedAuthor{*author}
+	}
+	names := []string{}
+	for i := 0; i < len(authors); i++ {
+		if name := strings.TrimSpace(authors[i].Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// Maps a json feed onto the rss model. Items without their own authors inherit the feeds authors.
+func (j *JSONFeed) toRSS() *RSSFeed {
+	rss := &RSSFeed{}
+	rss.Channel.Title = j.Title
+	rss.Channel.Link = j.HomePageURL
+	rss.Channel.Description = j.Description
+	feed_authors := jsonFeedAuthorNames(j.Authors, j.Author)
+	for i := 0; i < len(j.Items); i++ {
+		item := j.Items[i]
+
+		link := item.URL
+		if link == "" {
+			link = item.ExternalURL
+		}
+
+		description := item.ContentHTML
+		if description == "" {
+			description = item.ContentText
+		}
+		if description == "" {
+			description = item.Summary
+		}
+
+		pub_date := item.DatePublished
+		if pub_date == "" {
+			pub_date = item.DateModified
+		}
+
+		author := jsonFeedAuthorNames(item.Authors, item.Author)
+		if author == "" {
+			author = feed_authors
+		}
+
+		rss.Channel.Item = append(rss.Channel.Item, RSSItem{
+			Title:       item.Title,
+			Link:        link,
+			Description: description,
+			PubDate:     pub_date,
+			Author:      author,
+			Guid:        strings.TrimSpace(item.ID),
+		})
+	}
+	return rss
+}
+
+// Reports whether a response looks like a json feed, going by the content type first and then the body itself.
+func isJSONFeed(contentType string, data []byte) bool {
+	media_type := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if media_type == "application/feed+json" || media_type == "application/json" {
+		return true
+	}
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// Returns the local name of the documents root element, e.g. "rss", "feed" or "RDF".
+func rootElement(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// Detects the feed format from the content type and body and unmarshals it into the rss model.
+func parseFeed(contentType string, data []byte) (*RSSFeed, error) {
+	if isJSONFeed(contentType, data) {
+		my_json := &JSONFeed{}
+		err := json.Unmarshal(data, my_json)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling into jsonfeed: %w", err)
+		}
+		if !strings.Contains(my_json.Version, "jsonfeed.org") {
+			return nil, fmt.Errorf("json document is not a json feed")
+		}
+		return my_json.toRSS(), nil
+	}
+
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, fmt.Errorf("error finding root element of feed: %w", err)
+	}
+
+	switch root {
+	case "rss":
+		my_rss := &RSSFeed{}
+		err = xml.Unmarshal(data, my_rss)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling into rssfeed: %w", err)
+		}
+		return my_rss, nil
+	case "feed":
+		my_atom := &AtomFeed{}
+		err = xml.Unmarshal(data, my_atom)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling into atomfeed: %w", err)
+		}
+		return my_atom.toRSS(), nil
+	case "RDF":
+		my_rdf := &RDFFeed{}
+		err = xml.Unmarshal(data, my_rdf)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling into rdffeed: %w", err)
+		}
+		return my_rdf.toRSS(), nil
+	default:
+		return nil, fmt.Errorf("unsupported feed format with root element <%v>", root)
+	}
 }
 
 func cleanRSSData(rss *RSSFeed) {
@@ -34,28 +350,216 @@ func cleanRSSData(rss *RSSFeed) {
 	}
 }
 
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	my_client := &http.Client{}
+// Kinds of fetch failure the scheduler can act on. Check for them with errors.Is.
+var (
+	ErrFeedNotFound     = errors.New("feed not found")
+	ErrFeedGone         = errors.New("feed is gone")
+	ErrServerError      = errors.New("server error")
+	ErrRateLimited      = errors.New("rate limited")
+	ErrUnexpectedStatus = errors.New("unexpected response status")
+	ErrNotAFeed         = errors.New("response is not a feed")
+	ErrFeedTooLarge     = errors.New("feed exceeds maximum size")
+)
+
+// Error returned by fetchFeed when the server answered but the response can't be used.
+type FetchError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *FetchError) Error() string {
+	msg := e.Err.Error()
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%v (status %v)", msg, e.StatusCode)
+	}
+	if e.RetryAfter > 0 {
+		msg = fmt.Sprintf("%v, retry after %v", msg, e.RetryAfter)
+	}
+	return msg
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// Parses a Retry-After header, which is either a number of seconds or an http date.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(when); wait > 0 {
+			return wait.Round(time.Second)
+		}
+	}
+	return 0
+}
+
+// Maps an unsuccessful response status onto a FetchError.
+func statusError(res *http.Response) error {
+	fetch_err := &FetchError{
+		StatusCode: res.StatusCode,
+		RetryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+	}
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		fetch_err.Err = ErrFeedNotFound
+	case res.StatusCode == http.StatusGone:
+		fetch_err.Err = ErrFeedGone
+	case res.StatusCode == http.StatusTooManyRequests:
+		fetch_err.Err = ErrRateLimited
+	case res.StatusCode >= 500:
+		fetch_err.Err = ErrServerError
+	default:
+		fetch_err.Err = ErrUnexpectedStatus
+	}
+	return fetch_err
+}
+
+// Content types that can't possibly hold a feed, so there's no point reading the body.
+func isNonFeedContentType(contentType string) bool {
+	media_type := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	prefixes := []string{"image/", "audio/", "video/", "font/", "application/pdf", "application/zip"}
+	for i := 0; i < len(prefixes); i++ {
+		if strings.HasPrefix(media_type, prefixes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Request settings for fetchFeed. ETag and LastModified are the cache validators from a previous fetch, sent so
+// the server can answer 304 Not Modified. MaxBytes caps the response size and defaults when zero.
+type fetchOptions struct {
+	ETag         string
+	LastModified string
+	MaxBytes     int64
+}
+
+// Result of fetching a feed. Feed and Body are empty when the server answered 304 Not Modified. MovedTo is set
+// when the request was permanently redirected (301/308) and holds the url the feed should now be fetched from.
+// FinalURL is wherever the redirects ended up.
+type fetchResult struct {
+	Feed         *RSSFeed
+	NotModified  bool
+	StatusCode   int
+	ContentType  string
+	Body         []byte
+	ETag         string
+	LastModified string
+	MovedTo      string
+	FinalURL     string
+}
+
+// Largest number of redirects followed for a single fetch, same as the http package default.
+const maxRedirects = 10
+
+// Fetches a url and parses the response as a feed.
+func fetchFeed(ctx context.Context, feedURL string, opts fetchOptions) (*fetchResult, error) {
+	result, err := fetchDocument(ctx, feedURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	if result.NotModified {
+		return result, nil
+	}
+
+	my_rss, err := parseFeed(result.ContentType, result.Body)
+	if err != nil {
+		return nil, &FetchError{StatusCode: result.StatusCode, Err: fmt.Errorf("%w: %v", ErrNotAFeed, err)}
+	}
+	cleanRSSData(my_rss)
+	result.Feed = my_rss
+
+	return result, nil
+}
+
+// Does the http side of fetching: conditional headers, redirects, status checks and the size limit. The body is
+// returned unparsed.
+func fetchDocument(ctx context.Context, feedURL string, opts fetchOptions) (*fetchResult, error) {
+	// follow every redirect, but only remember where the feed moved to while the chain is all permanent.
+	// a temporary hop means the location after it may change again so it shouldn't be stored
+	moved_to := ""
+	all_permanent := true
+	my_client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %v redirects", maxRedirects)
+			}
+			status := req.Response.StatusCode
+			if all_permanent && (status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect) {
+				moved_to = req.URL.String()
+			} else {
+				all_permanent = false
+			}
+			return nil
+		},
+	}
 	my_request, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error with establishing request with context: %w", err)
 	}
 	my_request.Header.Set("myapp", "gator 0.0")
+	if opts.ETag != "" {
+		my_request.Header.Set("If-None-Match", opts.ETag)
+	}
+	if opts.LastModified != "" {
+		my_request.Header.Set("If-Modified-Since", opts.LastModified)
+	}
 	res, err := my_client.Do(my_request)
 	if err != nil {
 		return nil, fmt.Errorf("error with getting response: %w", err)
 	}
-	res_bytes, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading body into bytes: %w", err)
+	defer res.Body.Close()
+
+	result := &fetchResult{
+		StatusCode:   res.StatusCode,
+		ContentType:  res.Header.Get("Content-Type"),
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		MovedTo:      moved_to,
+		FinalURL:     res.Request.URL.String(),
+	}
+	if res.StatusCode == http.StatusNotModified {
+		// a 304 may leave out the validators, in which case the ones we sent still apply
+		if result.ETag == "" {
+			result.ETag = opts.ETag
+		}
+		if result.LastModified == "" {
+			result.LastModified = opts.LastModified
+		}
+		result.NotModified = true
+		return result, nil
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, statusError(res)
 	}
 
-	my_rss := &RSSFeed{}
-	err = xml.Unmarshal(res_bytes, my_rss)
+	if isNonFeedContentType(result.ContentType) {
+		return nil, &FetchError{StatusCode: res.StatusCode, Err: fmt.Errorf("%w: content type %v", ErrNotAFeed, result.ContentType)}
+	}
+
+	// read at most one byte past the limit so oversized bodies can be told apart from ones that fit exactly
+	max_bytes := opts.MaxBytes
+	if max_bytes <= 0 {
+		max_bytes = config.DefaultMaxFeedBytes
+	}
+	if res.ContentLength > max_bytes {
+		return nil, &FetchError{StatusCode: res.StatusCode, Err: ErrFeedTooLarge}
+	}
+	res_bytes, err := io.ReadAll(io.LimitReader(res.Body, max_bytes+1))
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling into rssfeed: %w", err)
+		return nil, fmt.Errorf("error reading body into bytes: %w", err)
 	}
-	cleanRSSData(my_rss)
+	if int64(len(res_bytes)) > max_bytes {
+		return nil, &FetchError{StatusCode: res.StatusCode, Err: ErrFeedTooLarge}
+	}
+	result.Body = res_bytes
 
-	return my_rss, nil
+	return result, nil
 }
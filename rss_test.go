@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFeedAtom(t *testing.T) {
+	data := []byte(`<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Example Blog</title>
+  <subtitle>Notes and posts</subtitle>
+  <link rel="self" href="https://blog.example/atom.xml"/>
+  <link rel="alternate" href="https://blog.example/"/>
+  <entry>
+    <id>urn:uuid:1</id>
+    <title>First post</title>
+    <link rel="self" href="https://blog.example/entries/1.xml"/>
+    <link rel="alternate" href="https://blog.example/first"/>
+    <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Hello <b>world</b></p></div></content>
+    <summary>Ignored when there is content</summary>
+    <published>2024-03-05T14:30:00Z</published>
+    <updated>2024-03-06T09:00:00Z</updated>
+    <author><name>Ann</name></author>
+    <author><name>Bob</name></author>
+  </entry>
+  <entry>
+    <id>urn:uuid:2</id>
+    <title>Second post</title>
+    <link href="https://blog.example/second"/>
+    <summary>Only a summary</summary>
+    <updated>2024-03-07T10:00:00Z</updated>
+  </entry>
+</feed>`)
+
+	feed, err := parseFeed("application/atom+xml", data)
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	if feed.Channel.Title != "Example Blog" || feed.Channel.Description != "Notes and posts" {
+		t.Errorf("channel title, description = %q, %q", feed.Channel.Title, feed.Channel.Description)
+	}
+	if feed.Channel.Link != "https://blog.example/" {
+		t.Errorf("channel link = %q, want the alternate link", feed.Channel.Link)
+	}
+	if len(feed.Channel.Item) != 2 {
+		t.Fatalf("got %v items, want 2", len(feed.Channel.Item))
+	}
+
+	first := feed.Channel.Item[0]
+	want := RSSItem{
+		Title:       "First post",
+		Link:        "https://blog.example/first",
+		Description: `<div xmlns="http://www.w3.org/1999/xhtml"><p>Hello <b>world</b></p></div>`,
+		PubDate:     "2024-03-05T14:30:00Z",
+		Author:      "Ann, Bob",
+		Guid:        "urn:uuid:1",
+	}
+	if first != want {
+		t.Errorf("first item = %+v, want %+v", first, want)
+	}
+
+	second := feed.Channel.Item[1]
+	if second.Link != "https://blog.example/second" {
+		t.Errorf("second item link = %q", second.Link)
+	}
+	if second.Description != "Only a summary" {
+		t.Errorf("second item description = %q, want the summary", second.Description)
+	}
+	if second.PubDate != "2024-03-07T10:00:00Z" {
+		t.Errorf("second item date = %q, want the updated date", second.PubDate)
+	}
+}
+
+func TestIsJSONFeed(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		want        bool
+	}{
+		{"feed+json content type", "application/feed+json; charset=utf-8", "", true},
+		{"json content type", "application/json", "", true},
+		{"sniffed from body", "text/plain", "\xef\xbb\xbf \n {\"version\": \"x\"}", true},
+		{"xml body", "text/xml", "<rss></rss>", false},
+		{"empty body", "", "", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isJSONFeed(c.contentType, []byte(c.body)); got != c.want {
+				t.Errorf("isJSONFeed(%q, %q) = %v, want %v", c.contentType, c.body, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseFeedJSONFeed(t *testing.T) {
+	data := []byte(`{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "Example Blog",
+  "home_page_url": "https://blog.example/",
+  "authors": [{"name": "Ann"}, {"name": "Bob"}],
+  "items": [
+    {"id": "1", "url": "https://blog.example/first", "title": "First", "content_html": "<p>Hi</p>", "date_published": "2024-03-05T14:30:00Z"},
+    {"id": "2", "external_url": "https://other.example/", "title": "Second", "content_text": "Text", "date_modified": "2024-03-06T09:00:00Z", "authors": [{"name": "Cat"}]}
+  ]
+}`)
+
+	// no content type, so the body is sniffed
+	feed, err := parseFeed("", data)
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	if feed.Channel.Title != "Example Blog" || feed.Channel.Link != "https://blog.example/" {
+		t.Errorf("channel title, link = %q, %q", feed.Channel.Title, feed.Channel.Link)
+	}
+	want := []RSSItem{
+		{Title: "First", Link: "https://blog.example/first", Description: "<p>Hi</p>", PubDate: "2024-03-05T14:30:00Z", Author: "Ann, Bob", Guid: "1"},
+		{Title: "Second", Link: "https://other.example/", Description: "Text", PubDate: "2024-03-06T09:00:00Z", Author: "Cat", Guid: "2"},
+	}
+	if !slices.Equal(feed.Channel.Item, want) {
+		t.Errorf("items = %+v, want %+v", feed.Channel.Item, want)
+	}
+}
+
+func TestParseFeedJSONFeedVersion1Author(t *testing.T) {
+	data := []byte(`{
+  "version": "https://jsonfeed.org/version/1",
+  "title": "Old Blog",
+  "author": {"name": "Ann"},
+  "items": [{"id": "1", "url": "https://old.example/1", "summary": "Sum"}]
+}`)
+
+	feed, err := parseFeed("application/feed+json", data)
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	if len(feed.Channel.Item) != 1 {
+		t.Fatalf("got %v items, want 1", len(feed.Channel.Item))
+	}
+	if item := feed.Channel.Item[0]; item.Author != "Ann" || item.Description != "Sum" {
+		t.Errorf("item author, description = %q, %q, want the feed author and the summary", item.Author, item.Description)
+	}
+}
+
+func TestParseFeedRejectsOtherJSON(t *testing.T) {
+	for _, body := range []string{`{"version": "1.0", "items": []}`, `{"items": []}`} {
+		if _, err := parseFeed("application/json", []byte(body)); err == nil {
+			t.Errorf("parseFeed(%q) returned no error", body)
+		}
+	}
+}
+
+func TestParseFeedRDF(t *testing.T) {
+	data := []byte(`<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel rdf:about="https://news.example/">
+    <title>Example News</title>
+    <link>https://news.example/</link>
+    <description>Daily news</description>
+    <items>
+      <rdf:Seq>
+        <rdf:li rdf:resource="https://news.example/1"/>
+        <rdf:li rdf:resource="https://news.example/2"/>
+      </rdf:Seq>
+    </items>
+  </channel>
+  <item rdf:about="https://news.example/1">
+    <title>First story</title>
+    <link> https://news.example/1 </link>
+    <description>One</description>
+    <dc:date>2024-03-05T14:30:00Z</dc:date>
+    <dc:creator>Ann</dc:creator>
+  </item>
+  <item rdf:about="https://news.example/2">
+    <title>Second story</title>
+    <link>https://news.example/2</link>
+    <description>Two</description>
+    <dc:date>2024-03-06T09:00:00+01:00</dc:date>
+  </item>
+</rdf:RDF>`)
+
+	feed, err := parseFeed("application/rdf+xml", data)
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	if feed.Channel.Title != "Example News" || feed.Channel.Link != "https://news.example/" {
+		t.Errorf("channel title, link = %q, %q", feed.Channel.Title, feed.Channel.Link)
+	}
+	want := []RSSItem{
+		{Title: "First story", Link: "https://news.example/1", Description: "One", PubDate: "2024-03-05T14:30:00Z", Author: "Ann", Guid: "https://news.example/1"},
+		{Title: "Second story", Link: "https://news.example/2", Description: "Two", PubDate: "2024-03-06T09:00:00+01:00", Guid: "https://news.example/2"},
+	}
+	if !slices.Equal(feed.Channel.Item, want) {
+		t.Errorf("items = %+v, want %+v", feed.Channel.Item, want)
+	}
+	for _, item := range feed.Channel.Item {
+		if _, ok := parsePublishedAt(item.PubDate); !ok {
+			t.Errorf("dc:date %q of %q doesn't parse", item.PubDate, item.Title)
+		}
+	}
+}
+
+func TestFetchDocumentErrors(t *testing.T) {
+	cases := []struct {
+		name       string
+		handler    http.HandlerFunc
+		maxBytes   int64
+		want       error
+		retryAfter time.Duration
+	}{
+		{
+			name:    "not found",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			want:    ErrFeedNotFound,
+		},
+		{
+			name:    "gone",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusGone) },
+			want:    ErrFeedGone,
+		},
+		{
+			name: "rate limited",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "120")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			want:       ErrRateLimited,
+			retryAfter: 2 * time.Minute,
+		},
+		{
+			name:    "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) },
+			want:    ErrServerError,
+		},
+		{
+			name:     "too large",
+			handler:  func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(strings.Repeat("x", 100))) },
+			maxBytes: 50,
+			want:     ErrFeedTooLarge,
+		},
+		{
+			name: "too large without content length",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(strings.Repeat("x", 40)))
+				w.(http.Flusher).Flush()
+				w.Write([]byte(strings.Repeat("x", 40)))
+			},
+			maxBytes: 50,
+			want:     ErrFeedTooLarge,
+		},
+		{
+			name: "image",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				w.Write([]byte("\x89PNG"))
+			},
+			want: ErrNotAFeed,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(c.handler)
+			defer server.Close()
+
+			_, err := fetchDocument(context.Background(), server.URL, fetchOptions{MaxBytes: c.maxBytes})
+			if !errors.Is(err, c.want) {
+				t.Fatalf("fetchDocument() error = %v, want %v", err, c.want)
+			}
+			var fetch_err *FetchError
+			if !errors.As(err, &fetch_err) {
+				t.Fatalf("fetchDocument() error = %T, want *FetchError", err)
+			}
+			if fetch_err.RetryAfter != c.retryAfter {
+				t.Errorf("RetryAfter = %v, want %v", fetch_err.RetryAfter, c.retryAfter)
+			}
+		})
+	}
+}
+
+func TestFetchDocumentWithinLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(strings.Repeat("x", 50)))
+	}))
+	defer server.Close()
+
+	result, err := fetchDocument(context.Background(), server.URL, fetchOptions{MaxBytes: 50})
+	if err != nil {
+		t.Fatalf("fetchDocument() error = %v", err)
+	}
+	if len(result.Body) != 50 {
+		t.Errorf("got %v bytes, want 50", len(result.Body))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("120"); got != 2*time.Minute {
+		t.Errorf("parseRetryAfter(\"120\") = %v, want 2m", got)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(%q) = %v, want about an hour", date, got)
+	}
+	for _, value := range []string{"", "-5", "soon", "Tue, 05 Mar 2024 14:30:00 GMT"} {
+		if got := parseRetryAfter(value); got != 0 {
+			t.Errorf("parseRetryAfter(%q) = %v, want 0", value, got)
+		}
+	}
+}
+
+func TestFetchDocumentRedirects(t *testing.T) {
+	// /hop0 redirects to /hop1 and so on with the given statuses, and the last hop redirects to /feed
+	cases := []struct {
+		name    string
+		hops    []int
+		movedTo string
+	}{
+		{"permanent", []int{http.StatusMovedPermanently}, "/feed"},
+		{"temporary then permanent", []int{http.StatusFound, http.StatusMovedPermanently}, ""},
+		{"permanent then temporary", []int{http.StatusMovedPermanently, http.StatusTemporaryRedirect}, "/hop1"},
+		{"all permanent", []int{http.StatusMovedPermanently, http.StatusPermanentRedirect}, "/feed"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			for i, status := range c.hops {
+				target := fmt.Sprintf("/hop%v", i+1)
+				if i == len(c.hops)-1 {
+					target = "/feed"
+				}
+				mux.Handle(fmt.Sprintf("/hop%v", i), http.RedirectHandler(target, status))
+			}
+			mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/rss+xml")
+				w.Write([]byte("<rss></rss>"))
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			result, err := fetchDocument(context.Background(), server.URL+"/hop0", fetchOptions{})
+			if err != nil {
+				t.Fatalf("fetchDocument() error = %v", err)
+			}
+			want_moved := ""
+			if c.movedTo != "" {
+				want_moved = server.URL + c.movedTo
+			}
+			if result.MovedTo != want_moved {
+				t.Errorf("MovedTo = %q, want %q", result.MovedTo, want_moved)
+			}
+			if result.FinalURL != server.URL+"/feed" {
+				t.Errorf("FinalURL = %q, want %q", result.FinalURL, server.URL+"/feed")
+			}
+		})
+	}
+}
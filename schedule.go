@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cbrookscode/blog_aggregator/internal/database"
+	"github.com/google/uuid"
+)
+
+// How many of a feeds most recent posts are looked at when learning its posting cadence.
+const cadenceSampleSize = 20
+
+// Fewest dated posts needed before a learned cadence is trusted over the default interval.
+const minCadenceSamples = 3
+
+// Length of each syndication module update period.
+var updatePeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Converts the hints parsed from a feed into the values stored on the feeds row. Values that don't make sense
+// are dropped rather than stored.
+func publisherHintsParams(feedID uuid.UUID, hints feedHints) database.UpdateFeedPublisherHintsParams {
+	params := database.UpdateFeedPublisherHintsParams{ID: feedID}
+
+	if ttl, err := strconv.Atoi(strings.TrimSpace(hints.TTL)); err == nil && ttl > 0 {
+		params.TtlMinutes = sql.NullInt32{Int32: int32(ttl), Valid: true}
+	}
+
+	hours := []string{}
+	for i := 0; i < len(hints.SkipHours); i++ {
+		hour, err := strconv.Atoi(strings.TrimSpace(hints.SkipHours[i]))
+		if err == nil && hour >= 0 && hour <= 24 && !slices.Contains(hours, strconv.Itoa(hour%24)) {
+			hours = append(hours, strconv.Itoa(hour%24))
+		}
+	}
+	if len(hours) > 0 {
+		params.SkipHours = sql.NullString{String: strings.Join(hours, ","), Valid: true}
+	}
+
+	days := []string{}
+	for i := 0; i < len(hints.SkipDays); i++ {
+		day := strings.ToLower(strings.TrimSpace(hints.SkipDays[i]))
+		if _, ok := weekdays[day]; ok && !slices.Contains(days, day) {
+			days = append(days, day)
+		}
+	}
+	if len(days) > 0 {
+		params.SkipDays = sql.NullString{String: strings.Join(days, ","), Valid: true}
+	}
+
+	period := strings.ToLower(strings.TrimSpace(hints.UpdatePeriod))
+	if _, ok := updatePeriods[period]; ok {
+		params.UpdatePeriod = sql.NullString{String: period, Valid: true}
+	}
+	if frequency, err := strconv.Atoi(strings.TrimSpace(hints.UpdateFrequency)); err == nil && frequency > 0 {
+		params.UpdateFrequency = sql.NullInt32{Int32: int32(frequency), Valid: true}
+	}
+
+	return params
+}
+
+// Returns the shortest interval the publisher asks to be polled at, from ttl and sy:updatePeriod/updateFrequency.
+// Zero means the feed doesn't say.
+func publisherMinInterval(feed database.Feed) (time.Duration, string) {
+	min_interval := time.Duration(0)
+	source := ""
+	if feed.TtlMinutes.Valid {
+		min_interval = time.Duration(feed.TtlMinutes.Int32) * time.Minute
+		source = "ttl"
+	}
+	if period, ok := updatePeriods[feed.UpdatePeriod.String]; ok {
+		frequency := int32(1)
+		if feed.UpdateFrequency.Valid {
+			frequency = feed.UpdateFrequency.Int32
+		}
+		if sy_interval := period / time.Duration(frequency); sy_interval > min_interval {
+			min_interval = sy_interval
+			source = "sy:updatePeriod"
+		}
+	}
+	return min_interval, source
+}
+
+// Returns how long after `from` the next fetch can happen once the feeds skipHours (GMT) and skipDays are taken
+// into account, starting from the earliest time the interval allows.
+func delayPastSkips(feed database.Feed, from time.Time, interval time.Duration) time.Duration {
+	skip_hours := map[int]bool{}
+	for _, hour := range strings.Split(feed.SkipHours.String, ",") {
+		if value, err := strconv.Atoi(hour); err == nil {
+			skip_hours[value] = true
+		}
+	}
+	skip_days := map[time.Weekday]bool{}
+	for _, day := range strings.Split(feed.SkipDays.String, ",") {
+		if weekday, ok := weekdays[day]; ok {
+			skip_days[weekday] = true
+		}
+	}
+	if len(skip_hours) == 0 && len(skip_days) == 0 {
+		return interval
+	}
+
+	// move forward to the top of the next hour until we land outside the skipped times. a week covers every
+	// combination so give up after that in case the publisher skips everything
+	next := from.Add(interval).UTC()
+	for i := 0; i < 7*24 && (skip_hours[next.Hour()] || skip_days[next.Weekday()]); i++ {
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+	return next.Sub(from)
+}
+
+// Works out how often to poll a feed from the publish times of its recent posts. The typical (median) gap between
+// posts is used, or the time since the latest post if the feed has gone quiet for longer than that, and the feed
+// is polled twice per gap so new posts show up reasonably quickly. Returns false when there isn't enough history.
+func adaptiveInterval(published []time.Time, now time.Time, minInterval, maxInterval time.Duration) (time.Duration, string, bool) {
+	if len(published) < minCadenceSamples {
+		return 0, "", false
+	}
+	times := slices.Clone(published)
+	slices.SortFunc(times, func(a, b time.Time) int { return b.Compare(a) })
+
+	gaps := []time.Duration{}
+	for i := 1; i < len(times); i++ {
+		gaps = append(gaps, times[i-1].Sub(times[i]))
+	}
+	slices.Sort(gaps)
+	median := gaps[len(gaps)/2]
+
+	gap := median
+	reason := fmt.Sprintf("posts about every %v over the last %v posts", median.Round(time.Minute), len(times))
+	if since_latest := now.Sub(times[0]); since_latest > median {
+		gap = since_latest
+		reason = fmt.Sprintf("no posts for %v, usually posts about every %v", since_latest.Round(time.Minute), median.Round(time.Minute))
+	}
+
+	interval := gap / 2
+	if interval < minInterval {
+		interval = minInterval
+		reason += fmt.Sprintf(", limited to the minimum of %v", minInterval)
+	} else if interval > maxInterval {
+		interval = maxInterval
+		reason += fmt.Sprintf(", limited to the maximum of %v", maxInterval)
+	}
+	return interval, reason, true
+}
+
+// Stores the interval a feed will be polled at from now on along with why it was chosen, and moves its next fetch
+// to match. Feeds with an interval set by setinterval keep it. The publishers ttl and sy:updatePeriod hints act as
+// a minimum for every interval, and skipHours/skipDays push the next fetch out of the times they cover.
+func scheduleFeed(ctx context.Context, s *state, feed database.Feed, defaultInterval time.Duration) error {
+	// re-read the feed so hints stored by the scrape that just finished are used
+	feed, err := s.db.GetFeedByID(ctx, feed.ID)
+	if err != nil {
+		return fmt.Errorf("error re-reading feed before scheduling: %w", err)
+	}
+
+	interval := defaultInterval
+	reason := "not enough dated posts to learn a cadence, using the default interval"
+	if feed.FetchIntervalSeconds.Valid {
+		interval = time.Duration(feed.FetchIntervalSeconds.Int32) * time.Second
+		reason = "interval set with setinterval"
+	} else {
+		rows, err := s.db.GetRecentPublishTimes(
+			ctx,
+			database.GetRecentPublishTimesParams{
+				FeedID: feed.ID,
+				Limit:  cadenceSampleSize,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("error getting publish times for feed %v: %w", feed.Url, err)
+		}
+		published := []time.Time{}
+		for i := 0; i < len(rows); i++ {
+			if rows[i].Valid {
+				published = append(published, rows[i].Time)
+			}
+		}
+
+		min_interval, max_interval := s.cfg.FetchIntervalBounds()
+		if learned, learned_reason, ok := adaptiveInterval(published, time.Now().UTC(), min_interval, max_interval); ok {
+			interval = learned
+			reason = learned_reason
+		}
+	}
+
+	if publisher_min, source := publisherMinInterval(feed); interval < publisher_min {
+		interval = publisher_min
+		reason += fmt.Sprintf(", raised to %v to respect the publishers %v", publisher_min, source)
+	}
+
+	delay := delayPastSkips(feed, time.Now().UTC(), interval)
+	if delay > interval {
+		reason += ", next fetch moved out of the publishers skipHours/skipDays"
+	}
+
+	err = s.db.UpdateFeedSchedule(
+		ctx,
+		database.UpdateFeedScheduleParams{
+			ScheduledIntervalSeconds: sql.NullInt32{Int32: int32(interval.Seconds()), Valid: true},
+			ScheduleReason:           sql.NullString{String: reason, Valid: true},
+			DelaySeconds:             int32(delay.Seconds()),
+			ID:                       feed.ID,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating schedule for feed %v: %w", feed.Url, err)
+	}
+	return nil
+}
+
+// Wait before the first retry of a failing feed. Each further failure doubles it.
+const baseRetryBackoff = 5 * time.Minute
+
+// Returns how long to wait before retrying a feed that has now failed `failures` times in a row.
+func retryBackoff(failures int, limit time.Duration) time.Duration {
+	backoff := baseRetryBackoff
+	for i := 1; i < failures && backoff < limit; i++ {
+		backoff *= 2
+	}
+	return min(backoff, limit)
+}
+
+// Records a failed fetch and pushes the feeds next attempt back exponentially, or to whenever the server asked us
+// to retry after if that's later. Feeds that are gone, or have failed too many times in a row, get disabled.
+func recordFeedFailure(ctx context.Context, s *state, feed database.Feed, fetchErr error) error {
+	failures := int(feed.ConsecutiveFailures) + 1
+	retry := retryBackoff(failures, s.cfg.RetryBackoffLimit())
+	var fetch_error *FetchError
+	if errors.As(fetchErr, &fetch_error) && fetch_error.RetryAfter > retry {
+		retry = fetch_error.RetryAfter
+	}
+
+	disable := false
+	if errors.Is(fetchErr, ErrFeedGone) {
+		disable = true
+		fmt.Printf("disabling feed %v: the server says it is gone\n", feed.Url)
+	} else if failures >= s.cfg.FailuresBeforeDisable() {
+		disable = true
+		fmt.Printf("disabling feed %v after %v consecutive failures\n", feed.Url, failures)
+	} else {
+		fmt.Printf("feed %v has failed %v times in a row, retrying in %v\n", feed.Url, failures, retry)
+	}
+
+	err := s.db.RecordFeedFailure(
+		ctx,
+		database.RecordFeedFailureParams{
+			LastError:    sql.NullString{String: fetchErr.Error(), Valid: true},
+			RetrySeconds: int32(retry.Seconds()),
+			Disable:      disable,
+			ID:           feed.ID,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("error recording failure for feed %v: %w", feed.Url, err)
+	}
+	return nil
+}
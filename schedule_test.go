@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/cbrookscode/blog_aggregator/internal/database"
+)
+
+func TestAdaptiveInterval(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	hourly := []time.Time{now.Add(-3 * time.Hour), now.Add(-1 * time.Hour), now.Add(-2 * time.Hour), now.Add(-4 * time.Hour)}
+
+	cases := []struct {
+		name      string
+		published []time.Time
+		min       time.Duration
+		max       time.Duration
+		want      time.Duration
+		ok        bool
+	}{
+		{"too few posts", hourly[:2], time.Minute, 24 * time.Hour, 0, false},
+		{"half the median gap", hourly, time.Minute, 24 * time.Hour, 30 * time.Minute, true},
+		{"limited to minimum", hourly, time.Hour, 24 * time.Hour, time.Hour, true},
+		{"limited to maximum", hourly, time.Minute, 10 * time.Minute, 10 * time.Minute, true},
+		{"quiet feed backs off", []time.Time{now.Add(-10 * time.Hour), now.Add(-11 * time.Hour), now.Add(-12 * time.Hour)}, time.Minute, 24 * time.Hour, 5 * time.Hour, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, _, ok := adaptiveInterval(c.published, now, c.min, c.max)
+			if ok != c.ok || got != c.want {
+				t.Errorf("adaptiveInterval() = %v, %v, want %v, %v", got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestDelayPastSkips(t *testing.T) {
+	// a Friday evening
+	from := time.Date(2024, time.March, 8, 23, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		skipHours string
+		skipDays  string
+		want      time.Duration
+	}{
+		{"no skips", "", "", time.Hour},
+		{"skipped hours", "0,1", "", 2*time.Hour + 30*time.Minute},
+		{"skipped days", "", "saturday", 24*time.Hour + 30*time.Minute},
+		{"everything skipped", "", "sunday,monday,tuesday,wednesday,thursday,friday,saturday", 7*24*time.Hour + 30*time.Minute},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			feed := database.Feed{
+				SkipHours: sql.NullString{String: c.skipHours, Valid: c.skipHours != ""},
+				SkipDays:  sql.NullString{String: c.skipDays, Valid: c.skipDays != ""},
+			}
+			if got := delayPastSkips(feed, from, time.Hour); got != c.want {
+				t.Errorf("delayPastSkips() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
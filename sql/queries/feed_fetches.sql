@@ -0,0 +1,20 @@
+-- name: CreateFeedFetch :exec
+INSERT INTO feed_fetches(id, feed_id, started_at, finished_at, http_status, bytes_read, items_seen, posts_inserted, duplicates_skipped, "error")
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+);
+
+-- name: GetFeedFetches :many
+SELECT * FROM feed_fetches
+WHERE feed_id = $1
+ORDER BY started_at DESC
+LIMIT $2;
\ No newline at end of file
@@ -25,7 +25,125 @@ UPDATE feeds
 SET last_fetched_at = NOW(), updated_at = NOW()
 WHERE id = $1;
 
+-- name: UpdateFeedCacheHeaders :exec
+UPDATE feeds
+SET etag = $2, last_modified = $3, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RecordFeedRedirect :one
+UPDATE feeds
+SET redirect_count = CASE WHEN redirect_url = $2 THEN redirect_count + 1 ELSE 1 END,
+    redirect_url = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING redirect_count;
+
+-- name: ClearFeedRedirect :exec
+UPDATE feeds
+SET redirect_url = NULL, redirect_count = 0, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateFeedUrl :exec
+UPDATE feeds
+SET previous_url = "url", "url" = $2, redirect_url = NULL, redirect_count = 0, updated_at = NOW()
+WHERE id = $1;
+
 -- name: GetNextFeedToFetch :one
 SELECT * FROM feeds
 ORDER BY last_fetched_at NULLS FIRST
-LIMIT 1;
\ No newline at end of file
+LIMIT 1;
+
+-- name: ClaimNextFeedToFetch :one
+UPDATE feeds
+SET last_fetched_at = NOW(),
+    next_fetch_at = NOW() + (COALESCE(fetch_interval_seconds, scheduled_interval_seconds, sqlc.arg('default_interval_seconds')::integer) * INTERVAL '1 second'),
+    updated_at = NOW()
+WHERE id = (
+    SELECT id FROM feeds
+    WHERE disabled_at IS NULL
+        AND (next_fetch_at IS NULL OR next_fetch_at <= sqlc.arg('due_by')::timestamptz)
+    ORDER BY next_fetch_at NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: GetSecondsUntilNextFetch :one
+SELECT COALESCE(
+    EXTRACT(EPOCH FROM MIN(COALESCE(next_fetch_at, NOW())) - NOW()),
+    sqlc.arg('idle_seconds')::float8
+)::float8 AS seconds_until_next_fetch
+FROM feeds
+WHERE disabled_at IS NULL;
+
+-- name: SetFeedInterval :exec
+UPDATE feeds
+SET fetch_interval_seconds = $2,
+    next_fetch_at = last_fetched_at + ($2 * INTERVAL '1 second'),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetFeedRetention :exec
+UPDATE feeds
+SET retention_max_posts = $2,
+    retention_max_age_seconds = $3,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateFeedSchedule :exec
+UPDATE feeds
+SET scheduled_interval_seconds = sqlc.arg('scheduled_interval_seconds'),
+    schedule_reason = sqlc.arg('schedule_reason'),
+    next_fetch_at = last_fetched_at + (sqlc.arg('delay_seconds')::integer * INTERVAL '1 second'),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id');
+
+-- name: UpdateFeedPublisherHints :exec
+UPDATE feeds
+SET ttl_minutes = $2,
+    skip_hours = $3,
+    skip_days = $4,
+    update_period = $5,
+    update_frequency = $6,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetFeedByID :one
+SELECT * FROM feeds WHERE id = $1;
+
+-- name: RecordFeedFailure :exec
+UPDATE feeds
+SET consecutive_failures = consecutive_failures + 1,
+    last_error = sqlc.arg('last_error'),
+    next_fetch_at = NOW() + (sqlc.arg('retry_seconds')::integer * INTERVAL '1 second'),
+    disabled_at = CASE WHEN sqlc.arg('disable')::boolean THEN NOW() ELSE disabled_at END,
+    updated_at = NOW()
+WHERE id = sqlc.arg('id');
+
+-- name: ResetFeedFailures :exec
+UPDATE feeds
+SET consecutive_failures = 0, last_error = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: ReenableFeed :exec
+UPDATE feeds
+SET consecutive_failures = 0, last_error = NULL, disabled_at = NULL, next_fetch_at = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkFeedDue :exec
+UPDATE feeds
+SET next_fetch_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetBrokenFeeds :many
+SELECT * FROM feeds
+WHERE consecutive_failures > 0 OR disabled_at IS NOT NULL
+ORDER BY disabled_at NULLS LAST, consecutive_failures DESC;
+
+-- name: ClaimFeed :one
+UPDATE feeds
+SET last_fetched_at = NOW(),
+    next_fetch_at = NOW() + (COALESCE(fetch_interval_seconds, scheduled_interval_seconds, sqlc.arg('default_interval_seconds')::integer) * INTERVAL '1 second'),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id')
+RETURNING *;
\ No newline at end of file
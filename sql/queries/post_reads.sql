@@ -0,0 +1,23 @@
+-- name: MarkAllPostsRead :execrows
+INSERT INTO post_reads(user_id, post_id, read_at)
+SELECT sqlc.arg('user_id')::uuid, posts.id, NOW()
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE feed_follows.user_id = sqlc.arg('user_id')
+    AND (sqlc.narg('feed_url')::text IS NULL OR feeds.url = sqlc.narg('feed_url'))
+    AND (sqlc.narg('before')::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < sqlc.narg('before'))
+ON CONFLICT DO NOTHING;
+
+-- name: MarkPostRead :execrows
+INSERT INTO post_reads(user_id, post_id, read_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING;
+
+-- name: MarkPostUnread :execrows
+DELETE FROM post_reads
+WHERE user_id = $1 AND post_id = $2;
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- name: CreatePostRevision :exec
+INSERT INTO post_revisions(id, post_id, created_at, title, "description", published_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+);
+
+-- name: GetPostRevisions :many
+SELECT * FROM post_revisions
+WHERE post_id = $1
+ORDER BY created_at;
\ No newline at end of file
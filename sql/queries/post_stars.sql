@@ -0,0 +1,24 @@
+-- name: GetStarredPostsForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts."url", posts."description", posts.published_at, posts.feed_id, posts.author, posts.guid, posts.dedupe_key,
+    feeds.name AS feed_name,
+    post_stars.starred_at
+FROM post_stars
+INNER JOIN posts ON post_stars.post_id = posts.id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE post_stars.user_id = $1
+ORDER BY post_stars.starred_at DESC
+LIMIT $2;
+
+-- name: StarPost :execrows
+INSERT INTO post_stars(user_id, post_id, starred_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnstarPost :execrows
+DELETE FROM post_stars
+WHERE user_id = $1 AND post_id = $2;
\ No newline at end of file
@@ -1,5 +1,32 @@
--- name: CreatePost :one
-INSERT INTO posts(id, created_at, updated_at, title, "url", "description", published_at, feed_id)
+-- name: CountPrunablePosts :many
+WITH ranked AS (
+    SELECT
+        posts.id,
+        posts.feed_id,
+        posts.dedupe_key,
+        ROW_NUMBER() OVER (PARTITION BY posts.feed_id ORDER BY COALESCE(posts.published_at, posts.created_at) DESC, posts.id DESC) AS position,
+        COALESCE(posts.published_at, posts.created_at) AS sort_time,
+        COALESCE(feeds.retention_max_posts, sqlc.narg('default_max_posts')::integer) AS max_posts,
+        COALESCE(feeds.retention_max_age_seconds, sqlc.narg('default_max_age_seconds')::integer) AS max_age_seconds
+    FROM posts
+    INNER JOIN feeds ON posts.feed_id = feeds.id
+), expired AS (
+    SELECT ranked.id, ranked.feed_id, ranked.dedupe_key
+    FROM ranked
+    WHERE ((ranked.position > ranked.max_posts) OR (ranked.sort_time < NOW() - (ranked.max_age_seconds * INTERVAL '1 second')))
+        AND NOT EXISTS (SELECT 1 FROM post_stars WHERE post_stars.post_id = ranked.id)
+)
+SELECT
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    COUNT(*) AS post_count
+FROM expired
+INNER JOIN feeds ON expired.feed_id = feeds.id
+GROUP BY feeds.id, feeds.name, feeds.url
+ORDER BY feeds.name;
+
+-- name: CreatePost :exec
+INSERT INTO posts(id, created_at, updated_at, title, "url", "description", published_at, feed_id, author, guid, dedupe_key)
 VALUES (
     $1,
     $2,
@@ -8,11 +35,133 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
-)
-RETURNING *;
+    $8,
+    $9,
+    $10,
+    $11
+);
+
+-- name: GetGuidlessPostByFeedAndUrl :one
+SELECT id, created_at, updated_at, title, "url", "description", published_at, feed_id, author, guid, dedupe_key
+FROM posts
+WHERE feed_id = $1 AND "url" = $2 AND guid IS NULL
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetPostByDedupeKey :one
+SELECT id, created_at, updated_at, title, "url", "description", published_at, feed_id, author, guid, dedupe_key
+FROM posts
+WHERE feed_id = $1 AND dedupe_key = $2;
+
+-- name: GetPostByID :one
+SELECT id, created_at, updated_at, title, "url", "description", published_at, feed_id, author, guid, dedupe_key
+FROM posts
+WHERE id = $1;
+
+-- name: GetPostsByUrl :many
+SELECT id, created_at, updated_at, title, "url", "description", published_at, feed_id, author, guid, dedupe_key
+FROM posts
+WHERE "url" = $1
+ORDER BY created_at;
 
 -- name: GetPostsForUser :many
-SELECT * FROM posts
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts."url", posts."description", posts.published_at, posts.feed_id, posts.author, posts.guid, posts.dedupe_key,
+    feeds.name AS feed_name
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE feed_follows.user_id = sqlc.arg('user_id')
+    AND (sqlc.narg('feed_url')::text IS NULL OR feeds.url = sqlc.narg('feed_url'))
+    AND (sqlc.narg('since')::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= sqlc.narg('since'))
+    AND (sqlc.narg('before')::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < sqlc.narg('before'))
+    AND (sqlc.narg('after_time')::timestamp IS NULL OR (COALESCE(posts.published_at, posts.created_at), posts.id) < (sqlc.narg('after_time'), sqlc.narg('after_id')::uuid))
+    AND (NOT sqlc.arg('unread_only')::boolean OR NOT EXISTS (SELECT 1 FROM post_reads WHERE post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id))
+ORDER BY COALESCE(posts.published_at, posts.created_at) DESC, posts.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetRecentPublishTimes :many
+SELECT published_at FROM posts
+WHERE feed_id = $1 AND published_at IS NOT NULL
 ORDER BY published_at DESC
-LIMIT $1;
\ No newline at end of file
+LIMIT $2;
+
+-- name: IsPostPruned :one
+SELECT EXISTS (
+    SELECT 1 FROM pruned_posts
+    WHERE feed_id = $1 AND dedupe_key = $2
+);
+
+-- name: LinkSameArticle :exec
+INSERT INTO same_articles(post_id, same_as_post_id, created_at)
+SELECT sqlc.arg('post_id')::uuid, id, NOW()
+FROM posts
+WHERE "url" = sqlc.arg('url') AND feed_id <> sqlc.arg('feed_id')
+ON CONFLICT DO NOTHING;
+
+-- name: PrunePosts :many
+WITH ranked AS (
+    SELECT
+        posts.id,
+        posts.feed_id,
+        posts.dedupe_key,
+        ROW_NUMBER() OVER (PARTITION BY posts.feed_id ORDER BY COALESCE(posts.published_at, posts.created_at) DESC, posts.id DESC) AS position,
+        COALESCE(posts.published_at, posts.created_at) AS sort_time,
+        COALESCE(feeds.retention_max_posts, sqlc.narg('default_max_posts')::integer) AS max_posts,
+        COALESCE(feeds.retention_max_age_seconds, sqlc.narg('default_max_age_seconds')::integer) AS max_age_seconds
+    FROM posts
+    INNER JOIN feeds ON posts.feed_id = feeds.id
+), expired AS (
+    SELECT ranked.id, ranked.feed_id, ranked.dedupe_key
+    FROM ranked
+    WHERE ((ranked.position > ranked.max_posts) OR (ranked.sort_time < NOW() - (ranked.max_age_seconds * INTERVAL '1 second')))
+        AND NOT EXISTS (SELECT 1 FROM post_stars WHERE post_stars.post_id = ranked.id)
+), deleted AS (
+    DELETE FROM posts
+    WHERE posts.id IN (SELECT expired.id FROM expired)
+    RETURNING posts.feed_id, posts.dedupe_key
+), tombstoned AS (
+    INSERT INTO pruned_posts(feed_id, dedupe_key, pruned_at)
+    SELECT deleted.feed_id, deleted.dedupe_key, NOW()
+    FROM deleted
+    ON CONFLICT (feed_id, dedupe_key) DO UPDATE SET pruned_at = EXCLUDED.pruned_at
+)
+SELECT
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    COUNT(*) AS post_count
+FROM deleted
+INNER JOIN feeds ON deleted.feed_id = feeds.id
+GROUP BY feeds.id, feeds.name, feeds.url
+ORDER BY feeds.name;
+
+-- name: SearchPostsForUser :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    feeds.name AS feed_name,
+    ts_rank(posts.search_vector, websearch_to_tsquery('english', sqlc.arg('query'))) AS rank,
+    ts_headline('english', regexp_replace(posts.description, '<[^>]*>', ' ', 'g'), websearch_to_tsquery('english', sqlc.arg('query')), 'MaxFragments=2, MaxWords=20, MinWords=5, StartSel=*, StopSel=*') AS snippet
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE feed_follows.user_id = sqlc.arg('user_id')
+    AND posts.search_vector @@ websearch_to_tsquery('english', sqlc.arg('query'))
+    AND (sqlc.narg('feed_url')::text IS NULL OR feeds.url = sqlc.narg('feed_url'))
+    AND (sqlc.narg('since')::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= sqlc.narg('since'))
+    AND (sqlc.narg('before')::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < sqlc.narg('before'))
+ORDER BY rank DESC, COALESCE(posts.published_at, posts.created_at) DESC
+LIMIT sqlc.arg('limit');
+
+-- name: UpdatePostContent :exec
+UPDATE posts
+SET title = $2,
+    "description" = $3,
+    published_at = $4,
+    author = $5,
+    updated_at = $6,
+    dedupe_key = $7,
+    guid = $8
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN author TEXT;
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN author;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN redirect_url TEXT,
+    ADD COLUMN redirect_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN previous_url TEXT;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN redirect_url,
+    DROP COLUMN redirect_count,
+    DROP COLUMN previous_url;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN scheduled_interval_seconds INTEGER,
+    ADD COLUMN schedule_reason TEXT;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN scheduled_interval_seconds,
+    DROP COLUMN schedule_reason;
\ No newline at end of file
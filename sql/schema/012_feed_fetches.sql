@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE feed_fetches (
+    id UUID PRIMARY KEY,
+    feed_id UUID NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL,
+    http_status INTEGER,
+    bytes_read BIGINT NOT NULL,
+    items_seen INTEGER NOT NULL,
+    posts_inserted INTEGER NOT NULL,
+    duplicates_skipped INTEGER NOT NULL,
+    "error" TEXT
+);
+
+CREATE INDEX feed_fetches_feed_id_started_at_idx ON feed_fetches (feed_id, started_at DESC);
+
+-- +goose Down
+DROP TABLE feed_fetches;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE post_revisions (
+    id UUID PRIMARY KEY,
+    post_id UUID NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    title TEXT,
+    "description" TEXT NOT NULL,
+    published_at TIMESTAMP
+);
+
+CREATE INDEX post_revisions_post_id_created_at_idx ON post_revisions (post_id, created_at);
+
+-- +goose Down
+DROP TABLE post_revisions;
\ No newline at end of file
@@ -0,0 +1,34 @@
+-- +goose Up
+ALTER TABLE posts
+    ADD COLUMN guid TEXT,
+    ADD COLUMN dedupe_key TEXT;
+
+-- existing posts have no guid, so they get the url + title hash scrapeFeed falls back to
+UPDATE posts SET dedupe_key = 'hash:' || encode(sha256(convert_to("url" || E'\n' || COALESCE(title, ''), 'UTF8')), 'hex');
+
+ALTER TABLE posts
+    ALTER COLUMN dedupe_key SET NOT NULL,
+    DROP CONSTRAINT posts_url_key,
+    ADD CONSTRAINT posts_feed_id_dedupe_key_key UNIQUE (feed_id, dedupe_key);
+
+CREATE INDEX posts_url_idx ON posts ("url");
+
+CREATE TABLE same_articles (
+    post_id UUID NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    same_as_post_id UUID NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (post_id, same_as_post_id)
+);
+
+CREATE INDEX same_articles_same_as_post_id_idx ON same_articles (same_as_post_id);
+
+-- +goose Down
+DROP TABLE same_articles;
+
+DROP INDEX posts_url_idx;
+
+ALTER TABLE posts
+    DROP CONSTRAINT posts_feed_id_dedupe_key_key,
+    ADD CONSTRAINT posts_url_key UNIQUE ("url"),
+    DROP COLUMN guid,
+    DROP COLUMN dedupe_key;
\ No newline at end of file
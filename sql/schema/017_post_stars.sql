@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE post_stars (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    starred_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX post_stars_post_id_idx ON post_stars (post_id);
+
+-- +goose Down
+DROP TABLE post_stars;
\ No newline at end of file
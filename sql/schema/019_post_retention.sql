@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN retention_max_posts INTEGER,
+    ADD COLUMN retention_max_age_seconds INTEGER;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN retention_max_posts,
+    DROP COLUMN retention_max_age_seconds;
\ No newline at end of file
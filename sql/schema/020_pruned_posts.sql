@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE pruned_posts (
+    feed_id UUID NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+    dedupe_key TEXT NOT NULL,
+    pruned_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (feed_id, dedupe_key)
+);
+
+-- +goose Down
+DROP TABLE pruned_posts;
\ No newline at end of file